@@ -0,0 +1,54 @@
+package astparser
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+type Config struct {
+	InputDir      string
+	ExcludeRegexp string
+	IncludeRegexp string
+	// Interfaces are fully qualified interface types, e.g. junolab.net/lib_api/events.Event,
+	// LoadPackages lists the ones implemented by the parsed structs in StructDef.Implements.
+	Interfaces []string
+}
+
+func (c *Config) validate() error {
+	if c.IncludeRegexp != "" && c.ExcludeRegexp != "" {
+		return errors.New("both include and exclude regexps are set")
+	}
+
+	return nil
+}
+
+func (c *Config) prepare() error {
+	if err := c.validate(); err != nil {
+		return err
+	}
+
+	if c.InputDir == "" {
+		c.InputDir = "./"
+	}
+
+	return nil
+}
+
+func (c *Config) regexps() (include, exclude *regexp.Regexp, err error) {
+	if c.ExcludeRegexp != "" {
+		exclude, err = regexp.Compile(c.ExcludeRegexp)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to compile exclude regexp %q: %v", c.ExcludeRegexp, err)
+		}
+	}
+
+	if c.IncludeRegexp != "" {
+		include, err = regexp.Compile(c.IncludeRegexp)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to compile include regexp %q: %v", c.IncludeRegexp, err)
+		}
+	}
+
+	return include, exclude, nil
+}
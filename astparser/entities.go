@@ -0,0 +1,120 @@
+// Package astparser parses golang structs, named types and constants with go/ast.
+// It started as a copy of github.com/mkorolyov/astparser and is extended here
+// with everything genavro needs to know about the sources.
+package astparser
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// ParsedFile contains declarations of the parsed file.
+// Package is an import path of the package the declarations belong to,
+// it is empty for the parsed package and set for the types followed by LoadPackages.
+type ParsedFile struct {
+	Package   string
+	Structs   []StructDef
+	Types     []TypeDef
+	Constants []ConstantDef
+}
+
+// Type represent parsed type.
+type Type interface{}
+
+// ConstantDef describes defined constants.
+// Type is set for typed constants, including the ones
+// which inherit the type inside a const group.
+// Value is empty if constant value is not a basic literal, e.g. iota.
+// TypePackage is an import path of the package the type is declared in
+// if it differs from the package of the constant, e.g. for constants of geo.Kind type.
+type ConstantDef struct {
+	Name        string
+	Value       string
+	Type        string
+	TypePackage string
+	Pos         token.Position
+}
+
+// StructDef describes parsed go struct.
+// Implements contains interfaces of Config.Interfaces the struct or pointer to it implements,
+// it is filled by LoadPackages only.
+type StructDef struct {
+	Name       string
+	Fields     []FieldDef
+	Comments   []string
+	Pos        token.Position
+	Implements []string
+}
+
+// TypeDef describes named non struct type, e.g. `type Status string`.
+type TypeDef struct {
+	Name     string
+	Type     Type
+	Comments []string
+	Pos      token.Position
+}
+
+// Tag contains parsed field tags.
+type Tag struct {
+	JsonName string
+
+	Omitempty bool
+}
+
+// FieldDef described parsed go struct field.
+// Tag is a raw field tag without quotes, e.g. `json:"name" avro:"logical=date"`.
+// Embedded field is named after its type.
+type FieldDef struct {
+	FieldName string
+	FieldType Type
+	JsonName  string
+	Omitempty bool
+	Embedded  bool
+	Tag       string
+	Comments  []string
+	Pos       token.Position
+}
+
+// TypeSimple indicates that type is a primitive golang type like int or string.
+type TypeSimple struct {
+	Name string
+}
+
+// TypeArray indicates that type is golang array or slice.
+// Inner type could be any type golang supports.
+// Len is a length of the fixed size array, 0 for slices
+// and ArrayLenUnknown if the length is a constant expression which could not be evaluated from the syntax.
+type TypeArray struct {
+	InnerType Type
+	Len       int
+}
+
+// ArrayLenUnknown is a length of the array declared with constant expression, e.g. [sha256.Size]byte.
+const ArrayLenUnknown = -1
+
+// TypeMap indicates that type is golang map.
+// Both keys and values could be any type golang supports.
+type TypeMap struct {
+	KeyType   Type
+	ValueType Type
+}
+
+// TypeCustomer indicates that type is a defined struct or type alias.
+// Package is an import path of the package the type is declared in,
+// it is empty for types declared in the parsed package.
+type TypeCustom struct {
+	Name    string
+	Package string
+	Expr    ast.Expr
+}
+
+// TypePointer indicates that type is a point with underlying any golang type
+type TypePointer struct {
+	InnerType Type
+}
+
+// TypeUnsupported indicates that type could not be parsed to the other types, e.g. func, chan, interface
+// or anonymous struct. Name is the go syntax of the type.
+type TypeUnsupported struct {
+	Name string
+}
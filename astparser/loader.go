@@ -14,7 +14,7 @@ import (
 )
 
 // Load parses files and return a map where key is a file name and
-// value as a parsed file obj with golang structs definitions and constants
+// value as a parsed file obj with golang structs, named types definitions and constants
 func Load(cfg Config) (map[string]ParsedFile, error) {
 	if err := cfg.prepare(); err != nil {
 		return nil, errors.Wrapf(err, "unexpected config %+v", cfg)
@@ -32,7 +32,7 @@ func Load(cfg Config) (map[string]ParsedFile, error) {
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse file %s", filePath)
 		}
-		result[f] = ParsedFile{Structs: file.Structs, Constants: file.Constants}
+		result[f] = file
 	}
 
 	return result, nil
@@ -44,9 +44,16 @@ func parseFile(file string) (ParsedFile, error) {
 	if err != nil {
 		return ParsedFile{}, errors.Wrapf(err, "cant parse file: %s", file)
 	}
-	walker := &Walker{}
+	walker := &Walker{Fset: fileSet}
 	ast.Walk(walker, parsedFile)
-	return ParsedFile{Structs: walker.Structs, Constants: walker.Constants}, nil
+	if len(walker.Errors) > 0 {
+		msgs := make([]string, 0, len(walker.Errors))
+		for _, err := range walker.Errors {
+			msgs = append(msgs, err.Error())
+		}
+		return ParsedFile{}, errors.New(strings.Join(msgs, "\n"))
+	}
+	return ParsedFile{Structs: walker.Structs, Types: walker.Types, Constants: walker.Constants}, nil
 }
 
 func getFilesNames(cfg Config) ([]string, error) {
@@ -56,19 +63,9 @@ func getFilesNames(cfg Config) ([]string, error) {
 		return nil, fmt.Errorf("failed to read input dir %s: %s", cfg.InputDir, err)
 	}
 
-	var includeRegexp, excludeRegexp *regexp.Regexp
-	if cfg.ExcludeRegexp != "" {
-		excludeRegexp, err = regexp.Compile(cfg.ExcludeRegexp)
-		if err != nil {
-			return nil, fmt.Errorf("failed to compile exclude regexp %q: %v", cfg.ExcludeRegexp, err)
-		}
-	}
-
-	if cfg.IncludeRegexp != "" {
-		includeRegexp, err = regexp.Compile(cfg.IncludeRegexp)
-		if err != nil {
-			return nil, fmt.Errorf("failed to compile exclude regexp %q: %v", cfg.IncludeRegexp, err)
-		}
+	includeRegexp, excludeRegexp, err := cfg.regexps()
+	if err != nil {
+		return nil, err
 	}
 
 	for _, f := range files {
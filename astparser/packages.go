@@ -0,0 +1,384 @@
+package astparser
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/tools/go/packages"
+)
+
+const packagesLoadMode = packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedDeps |
+	packages.NeedTypes | packages.NeedSyntax | packages.NeedTypesInfo
+
+// LoadPackages loads and type checks go package from the input dir with go/packages.
+// It respects go modules and vendor directories and never goes to the network.
+// Unlike Load it resolves types across packages: named types declared in the imported
+// packages are followed and their definitions are added to the result under their file paths,
+// aliases are resolved to the aliased types.
+// Types with custom json or text marshaling are not followed as their json shape is unknown.
+// Include and exclude regexps are applied to the files of the loaded package only.
+func LoadPackages(cfg Config) (map[string]ParsedFile, error) {
+	if err := cfg.prepare(); err != nil {
+		return nil, errors.Wrapf(err, "unexpected config %+v", cfg)
+	}
+
+	includeRegexp, excludeRegexp, err := cfg.regexps()
+	if err != nil {
+		return nil, err
+	}
+
+	fset := token.NewFileSet()
+	pkgs, err := packages.Load(&packages.Config{
+		Mode:  packagesLoadMode,
+		Dir:   cfg.InputDir,
+		Fset:  fset,
+		Tests: true,
+		// never download modules
+		Env: append(os.Environ(), "GOPROXY=off"),
+	}, ".")
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load package from %s", cfg.InputDir)
+	}
+	if err := packagesErrors(pkgs); err != nil {
+		return nil, err
+	}
+
+	l := &packagesLoader{
+		fset:     fset,
+		packages: map[string]*packages.Package{},
+		roots:    map[string]bool{},
+		followed: map[*types.TypeName]bool{},
+		result:   map[string]ParsedFile{},
+	}
+
+	packages.Visit(pkgs, nil, func(p *packages.Package) {
+		if _, ok := l.packages[p.PkgPath]; !ok {
+			l.packages[p.PkgPath] = p
+		}
+	})
+
+	var roots []*packages.Package
+	for _, p := range pkgs {
+		// skip generated test main
+		if p.Name == "main" && strings.HasSuffix(p.ID, ".test") {
+			continue
+		}
+		l.roots[p.PkgPath] = true
+		roots = append(roots, p)
+	}
+
+	interfaces := l.interfaces(cfg.Interfaces, roots)
+	var errs []string
+	for _, p := range roots {
+		for _, file := range p.Syntax {
+			name := filepath.Base(fset.Position(file.Pos()).Filename)
+			// package and its test variant share files
+			if _, ok := l.result[name]; ok || !validFile(name, includeRegexp, excludeRegexp) {
+				continue
+			}
+
+			walker := &Walker{Fset: fset, Resolve: l.resolver(p.TypesInfo)}
+			ast.Walk(walker, file)
+			for _, err := range walker.Errors {
+				errs = append(errs, err.Error())
+			}
+			withImplements(p, walker.Structs, cfg.Interfaces, interfaces)
+			l.result[name] = ParsedFile{Structs: walker.Structs, Types: walker.Types, Constants: walker.Constants}
+		}
+	}
+
+	// follow types declared in other packages, definitions could refer to the new ones
+	for len(l.queue) > 0 {
+		obj := l.queue[0]
+		l.queue = l.queue[1:]
+		if err := l.define(obj); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "\n"))
+	}
+
+	return l.result, nil
+}
+
+func packagesErrors(pkgs []*packages.Package) error {
+	var errs []string
+	seen := map[string]bool{}
+	packages.Visit(pkgs, nil, func(p *packages.Package) {
+		for _, err := range p.Errors {
+			// test variants of the package report the same errors
+			if msg := err.Error(); !seen[msg] {
+				seen[msg] = true
+				errs = append(errs, msg)
+			}
+		}
+	})
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(errs, "\n"))
+}
+
+type packagesLoader struct {
+	fset *token.FileSet
+	// packages contains all loaded packages with their dependencies by path.
+	packages map[string]*packages.Package
+	// roots contains paths of the loaded packages, their types are not followed.
+	roots    map[string]bool
+	followed map[*types.TypeName]bool
+	queue    []*types.TypeName
+	result   map[string]ParsedFile
+}
+
+func (l *packagesLoader) resolver(info *types.Info) func(ast.Expr) (Type, error) {
+	return func(expr ast.Expr) (Type, error) {
+		t := info.TypeOf(expr)
+		if t == nil {
+			return nil, fmt.Errorf("unresolved type %s", types.ExprString(expr))
+		}
+		return l.typeOf(t)
+	}
+}
+
+// typeOf converts go/types type to the parsed type.
+func (l *packagesLoader) typeOf(t types.Type) (Type, error) {
+	switch v := t.(type) {
+	case *types.Alias:
+		return l.typeOf(types.Unalias(v))
+	case *types.Basic:
+		return TypeSimple{Name: v.Name()}, nil
+	case *types.Pointer:
+		inner, err := l.typeOf(v.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return TypePointer{InnerType: inner}, nil
+	case *types.Slice:
+		inner, err := l.typeOf(v.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return TypeArray{InnerType: inner}, nil
+	case *types.Array:
+		inner, err := l.typeOf(v.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return TypeArray{InnerType: inner, Len: int(v.Len())}, nil
+	case *types.Map:
+		kt, err := l.typeOf(v.Key())
+		if err != nil {
+			return nil, err
+		}
+		vt, err := l.typeOf(v.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return TypeMap{KeyType: kt, ValueType: vt}, nil
+	case *types.Named:
+		obj := v.Obj()
+		if obj.Pkg() == nil {
+			return nil, fmt.Errorf("unexpected %s", t)
+		}
+		if l.roots[obj.Pkg().Path()] {
+			return TypeCustom{Name: obj.Name()}, nil
+		}
+		l.follow(obj)
+		return TypeCustom{Name: obj.Name(), Package: importPath(obj.Pkg().Path())}, nil
+	default:
+		return nil, fmt.Errorf("unexpected %s", t)
+	}
+}
+
+func (l *packagesLoader) follow(obj *types.TypeName) {
+	if l.followed[obj] {
+		return
+	}
+	l.followed[obj] = true
+
+	if hasCustomMarshaling(obj.Type()) {
+		return
+	}
+	l.queue = append(l.queue, obj)
+}
+
+// hasCustomMarshaling checks if value or pointer of the type implements json.Marshaler or encoding.TextMarshaler.
+func hasCustomMarshaling(t types.Type) bool {
+	methods := types.NewMethodSet(types.NewPointer(t))
+	return methods.Lookup(nil, "MarshalJSON") != nil || methods.Lookup(nil, "MarshalText") != nil
+}
+
+// interfaces finds loaded interface types by their qualified names, interfaces of the root packages
+// are named without package. Names of not loaded or not interface types are skipped.
+func (l *packagesLoader) interfaces(names []string, roots []*packages.Package) map[string]*types.Interface {
+	result := map[string]*types.Interface{}
+	for _, name := range names {
+		pkgs := roots
+		typeName := name
+		if i := strings.LastIndex(name, "."); i >= 0 {
+			pkgs, typeName = l.packagesOf(name[:i]), name[i+1:]
+		}
+
+		for _, p := range pkgs {
+			if p.Types == nil {
+				continue
+			}
+			obj, ok := p.Types.Scope().Lookup(typeName).(*types.TypeName)
+			if !ok {
+				continue
+			}
+			if iface, ok := obj.Type().Underlying().(*types.Interface); ok {
+				result[name] = iface
+			}
+		}
+	}
+	return result
+}
+
+// packagesOf returns loaded packages imported by the path, vendored ones included.
+func (l *packagesLoader) packagesOf(pkgPath string) []*packages.Package {
+	var pkgs []*packages.Package
+	for path, p := range l.packages {
+		if importPath(path) == pkgPath {
+			pkgs = append(pkgs, p)
+		}
+	}
+	return pkgs
+}
+
+// withImplements fills interfaces implemented by the structs of the package in order of names.
+func withImplements(p *packages.Package, structs []StructDef, names []string, interfaces map[string]*types.Interface) {
+	for i, s := range structs {
+		obj, ok := p.Types.Scope().Lookup(s.Name).(*types.TypeName)
+		if !ok {
+			continue
+		}
+		for _, name := range names {
+			iface, ok := interfaces[name]
+			if ok && (types.Implements(obj.Type(), iface) || types.Implements(types.NewPointer(obj.Type()), iface)) {
+				structs[i].Implements = append(structs[i].Implements, name)
+			}
+		}
+	}
+}
+
+// define adds definition of the type declared in other package to the result.
+func (l *packagesLoader) define(obj *types.TypeName) error {
+	p := l.packages[obj.Pkg().Path()]
+	if p == nil {
+		return fmt.Errorf("package %s of type %s is not loaded", obj.Pkg().Path(), obj.Name())
+	}
+
+	spec, doc := l.typeSpec(p, obj)
+	if spec == nil {
+		return fmt.Errorf("declaration of type %s.%s is not found", obj.Pkg().Path(), obj.Name())
+	}
+
+	walker := &Walker{Fset: l.fset, Resolve: l.resolver(p.TypesInfo)}
+	walker.visitType(spec, doc)
+
+	pos := l.fset.Position(obj.Pos())
+	file := l.result[pos.Filename]
+	file.Package = importPath(obj.Pkg().Path())
+	file.Structs = append(file.Structs, walker.Structs...)
+	file.Types = append(file.Types, walker.Types...)
+	if len(walker.Types) > 0 {
+		file.Constants = append(file.Constants, l.constants(obj)...)
+	}
+	l.result[pos.Filename] = file
+
+	if len(walker.Errors) > 0 {
+		msgs := make([]string, 0, len(walker.Errors))
+		for _, err := range walker.Errors {
+			msgs = append(msgs, err.Error())
+		}
+		return errors.New(strings.Join(msgs, "\n"))
+	}
+	return nil
+}
+
+// typeSpec finds declaration of the type with its doc in the package syntax.
+func (l *packagesLoader) typeSpec(p *packages.Package, obj *types.TypeName) (*ast.TypeSpec, *ast.CommentGroup) {
+	for _, file := range p.Syntax {
+		if file.Pos() > obj.Pos() || obj.Pos() > file.End() {
+			continue
+		}
+
+		for _, decl := range file.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range genDecl.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				if typeSpec.Name.Pos() != obj.Pos() {
+					continue
+				}
+
+				doc := typeSpec.Doc
+				if doc == nil && len(genDecl.Specs) == 1 {
+					doc = genDecl.Doc
+				}
+				return typeSpec, doc
+			}
+		}
+	}
+	return nil, nil
+}
+
+// importPath trims vendor directory prefix from the package path,
+// e.g. github.com/a/b/vendor/github.com/c/d is imported as github.com/c/d.
+func importPath(pkgPath string) string {
+	if i := strings.LastIndex(pkgPath, "/vendor/"); i >= 0 {
+		return pkgPath[i+len("/vendor/"):]
+	}
+	return strings.TrimPrefix(pkgPath, "vendor/")
+}
+
+// constants returns constants of the named type in order of declaration.
+func (l *packagesLoader) constants(obj *types.TypeName) []ConstantDef {
+	var consts []*types.Const
+	scope := obj.Pkg().Scope()
+	for _, name := range scope.Names() {
+		if c, ok := scope.Lookup(name).(*types.Const); ok && types.Identical(c.Type(), obj.Type()) {
+			consts = append(consts, c)
+		}
+	}
+
+	sort.Slice(consts, func(i, j int) bool {
+		pi, pj := l.fset.Position(consts[i].Pos()), l.fset.Position(consts[j].Pos())
+		if pi.Filename != pj.Filename {
+			return pi.Filename < pj.Filename
+		}
+		if pi.Line != pj.Line {
+			return pi.Line < pj.Line
+		}
+		return pi.Column < pj.Column
+	})
+
+	defs := make([]ConstantDef, 0, len(consts))
+	for _, c := range consts {
+		value := c.Val().ExactString()
+		if c.Val().Kind() == constant.String {
+			value = constant.StringVal(c.Val())
+		}
+		defs = append(defs, ConstantDef{
+			Name:  c.Name(),
+			Value: value,
+			Type:  obj.Name(),
+			Pos:   l.fset.Position(c.Pos()),
+		})
+	}
+	return defs
+}
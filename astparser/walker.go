@@ -0,0 +1,450 @@
+package astparser
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"log"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Walker implements go/ast.Visitor to walk through golang
+// structs, named types and constants to parse them.
+// Fset is used to resolve positions of parsed definitions, they are empty if it is not set.
+// Errors contains struct fields which failed to be parsed, such fields are skipped.
+// Fields and named types of the types which could not be parsed, e.g. funcs, channels and interfaces,
+// are not errors, their type is TypeUnsupported.
+// Resolve is used to resolve types of fields and named types instead of the plain syntax parsing if set,
+// e.g. with go/types info.
+type Walker struct {
+	Structs   []StructDef
+	Types     []TypeDef
+	Constants []ConstantDef
+	Errors    []error
+	Fset      *token.FileSet
+	Resolve   func(ast.Expr) (Type, error)
+
+	// imports maps package names used in the file to import paths.
+	imports map[string]string
+}
+
+// A Walkers's Visit method is invoked for each node encountered by go/ast.Walk.
+// If the result visitor w is not nil, go/ast.Walk visits each of the children
+// of node with the visitor w, followed by a call of w.Visit(nil).
+func (w *Walker) Visit(node ast.Node) ast.Visitor {
+	decl, ok := node.(*ast.GenDecl)
+	if !ok {
+		return w
+	}
+
+	switch decl.Tok {
+	case token.IMPORT:
+		for _, spec := range decl.Specs {
+			w.visitImport(spec.(*ast.ImportSpec))
+		}
+	case token.TYPE:
+		for _, spec := range decl.Specs {
+			typeSpec := spec.(*ast.TypeSpec)
+			doc := typeSpec.Doc
+			// doc of not grouped type declaration belongs to the declaration itself
+			if doc == nil && len(decl.Specs) == 1 {
+				doc = decl.Doc
+			}
+			w.visitType(typeSpec, doc)
+		}
+	case token.CONST:
+		w.visitConstants(decl)
+	case token.VAR:
+		for _, spec := range decl.Specs {
+			w.visitConstant(spec.(*ast.ValueSpec))
+		}
+	}
+
+	return nil
+}
+
+func (w *Walker) position(pos token.Pos) token.Position {
+	if w.Fset == nil {
+		return token.Position{}
+	}
+	return w.Fset.Position(pos)
+}
+
+func (w *Walker) visitImport(spec *ast.ImportSpec) {
+	path := removeQuotes(spec.Path.Value)
+	name := importName(path)
+	if spec.Name != nil {
+		name = spec.Name.Name
+	}
+
+	if w.imports == nil {
+		w.imports = map[string]string{}
+	}
+	w.imports[name] = path
+}
+
+// versionSuffixRegexp matches major version suffix of the import path,
+// e.g. /v2 of github.com/acme/money/v2 or .v3 of gopkg.in/yaml.v3.
+var versionSuffixRegexp = regexp.MustCompile(`[/.]v[0-9]+$`)
+
+// importName guesses name of the package imported without explicit name from the import path,
+// the package is not loaded so its declared name is unknown. Major version suffix is not a part of the name.
+func importName(path string) string {
+	if loc := versionSuffixRegexp.FindStringIndex(path); loc != nil && strings.Contains(path[:loc[0]], "/") {
+		path = path[:loc[0]]
+	}
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+// withPackage fills import paths of the custom types declared in other packages.
+func (w *Walker) withPackage(t Type) Type {
+	switch v := t.(type) {
+	case TypeCustom:
+		if _, ok := v.Expr.(*ast.SelectorExpr); ok {
+			v.Package = w.typePackage(v.Expr)
+		}
+		return v
+	case TypePointer:
+		return TypePointer{InnerType: w.withPackage(v.InnerType)}
+	case TypeArray:
+		v.InnerType = w.withPackage(v.InnerType)
+		return v
+	case TypeMap:
+		return TypeMap{KeyType: w.withPackage(v.KeyType), ValueType: w.withPackage(v.ValueType)}
+	default:
+		return t
+	}
+}
+
+func (w *Walker) visitConstant(astValueSpec *ast.ValueSpec) {
+	if len(astValueSpec.Names) < 1 || len(astValueSpec.Values) < 1 {
+		return
+	}
+	name := astValueSpec.Names[0].Name
+	var value string
+	switch v := astValueSpec.Values[0].(type) {
+	case *ast.BasicLit:
+		value = removeQuotes(v.Value)
+	default:
+		return
+	}
+
+	w.Constants = append(w.Constants, ConstantDef{
+		Name: name, Value: value, Pos: w.position(astValueSpec.Pos()),
+	})
+}
+
+// visitConstants parses const group keeping track of the implicit
+// type repetition, so every constant of
+//
+//	const (
+//		A Status = iota
+//		B
+//	)
+//
+// gets Status type.
+func (w *Walker) visitConstants(decl *ast.GenDecl) {
+	var groupType ast.Expr
+	for _, spec := range decl.Specs {
+		valueSpec := spec.(*ast.ValueSpec)
+		switch {
+		case valueSpec.Type != nil:
+			groupType = valueSpec.Type
+		case len(valueSpec.Values) > 0:
+			groupType = nil
+		}
+
+		for i, ident := range valueSpec.Names {
+			if ident.Name == "_" {
+				continue
+			}
+
+			c := ConstantDef{Name: ident.Name, Pos: w.position(ident.Pos())}
+			tpe := groupType
+			if i < len(valueSpec.Values) {
+				c.Value, tpe = constantValue(valueSpec.Values[i], tpe)
+			}
+			c.Type, c.TypePackage = typeName(tpe), w.typePackage(tpe)
+			if c.Value == "" && c.Type == "" {
+				continue
+			}
+
+			w.Constants = append(w.Constants, c)
+		}
+	}
+}
+
+// constantValue returns value of basic literal or typed conversion of the
+// basic literal like Status("active") along with the constant type.
+func constantValue(expr ast.Expr, tpe ast.Expr) (string, ast.Expr) {
+	switch v := expr.(type) {
+	case *ast.BasicLit:
+		return removeQuotes(v.Value), tpe
+	case *ast.CallExpr:
+		if len(v.Args) != 1 {
+			return "", tpe
+		}
+		if lit, ok := v.Args[0].(*ast.BasicLit); ok {
+			return removeQuotes(lit.Value), v.Fun
+		}
+	}
+
+	return "", tpe
+}
+
+// typePackage returns import path of the package the type is selected from, e.g. geo.Kind,
+// it is empty for the types of the parsed package.
+func (w *Walker) typePackage(expr ast.Expr) string {
+	if sel, ok := expr.(*ast.SelectorExpr); ok {
+		if pkg, ok := sel.X.(*ast.Ident); ok {
+			return w.imports[pkg.Name]
+		}
+	}
+	return ""
+}
+
+func typeName(expr ast.Expr) string {
+	switch v := expr.(type) {
+	case *ast.Ident:
+		return v.Name
+	case *ast.SelectorExpr:
+		return v.Sel.Name
+	default:
+		return ""
+	}
+}
+
+func (w *Walker) visitType(astTypeSpec *ast.TypeSpec, doc *ast.CommentGroup) {
+	structName := astTypeSpec.Name.Name
+
+	switch v := astTypeSpec.Type.(type) {
+	case *ast.StructType:
+		astFields := v.Fields.List
+
+		s := StructDef{
+			Name:     structName,
+			Comments: parseComments(doc),
+			Pos:      w.position(astTypeSpec.Pos())}
+
+		for _, astField := range astFields {
+			fields, err := parseFields(astField, w.parseTypeOrUnsupported)
+			if err != nil {
+				w.Errors = append(w.Errors, errors.Wrapf(err, "%s: failed to parse struct %s", w.position(astField.Pos()), structName))
+				continue
+			}
+			for i, field := range fields {
+				pos := astField.Pos()
+				if len(astField.Names) > 0 {
+					pos = astField.Names[i].Pos()
+				}
+				field.Pos = w.position(pos)
+				s.Fields = append(s.Fields, field)
+			}
+		}
+
+		w.Structs = append(w.Structs, s)
+
+	default:
+		t := w.parseTypeOrUnsupported(astTypeSpec.Type)
+		w.Types = append(w.Types, TypeDef{
+			Name:     structName,
+			Type:     t,
+			Comments: parseComments(doc),
+			Pos:      w.position(astTypeSpec.Pos()),
+		})
+	}
+}
+
+// parseType parses type expression with Resolve if it is set or with the plain syntax parsing otherwise.
+func (w *Walker) parseType(expr ast.Expr) (Type, error) {
+	if w.Resolve != nil {
+		return w.Resolve(expr)
+	}
+
+	t, err := parseFieldType(expr)
+	if err != nil {
+		return nil, err
+	}
+	return w.withPackage(t), nil
+}
+
+// parseTypeOrUnsupported parses type expression, types which could not be parsed are TypeUnsupported,
+// so declarations using them, e.g. fields skipped by json, do not fail the parsing.
+func (w *Walker) parseTypeOrUnsupported(expr ast.Expr) Type {
+	t, err := w.parseType(expr)
+	if err != nil {
+		return TypeUnsupported{Name: types.ExprString(expr)}
+	}
+	return t
+}
+
+// parseFields returns fields declared by the ast field, e.g. `A, B string` declares two fields
+// sharing the type, the tag and the comments.
+func parseFields(astField *ast.Field, parseType func(ast.Expr) Type) ([]FieldDef, error) {
+	fieldNames, err := parseFieldNames(astField.Names, astField.Type)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse field %+v name", *astField)
+	}
+	tag, err := parseJSONTag(astField.Tag)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse field %s tags", strings.Join(fieldNames, ", "))
+	}
+	fieldType := parseType(astField.Type)
+
+	fields := make([]FieldDef, 0, len(fieldNames))
+	for _, fieldName := range fieldNames {
+		field := FieldDef{
+			FieldName: fieldName,
+			FieldType: fieldType,
+			Omitempty: tag.Omitempty,
+			JsonName:  tag.JsonName,
+			Embedded:  len(astField.Names) == 0,
+			Comments:  parseComments(astField.Doc),
+		}
+		if astField.Tag != nil && astField.Tag.Value != "" {
+			field.Tag = removeQuotes(astField.Tag.Value)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+func parseComments(group *ast.CommentGroup) []string {
+	if group == nil || len(group.List) == 0 {
+		return nil
+	}
+
+	var comments []string
+	for _, c := range group.List {
+		if c == nil {
+			continue
+		}
+		comments = append(comments, strings.TrimSpace(strings.Replace(c.Text, "//", "", -1)))
+	}
+	return comments
+}
+
+func removeQuotes(s string) string {
+	if len(s) < 2 {
+		log.Fatalf("bad input for removing quotes: %s", s)
+	}
+
+	return s[1 : len(s)-1]
+}
+
+func parseJSONTag(astTag *ast.BasicLit) (Tag, error) {
+	if astTag == nil {
+		return Tag{}, nil
+	}
+	tagString := astTag.Value
+	if tagString == "" {
+		return Tag{}, nil
+	}
+	tagString = removeQuotes(tagString) //clean from `json:"place_type,omitempty"` to  json:"place_type,omitempty"
+	return parseTag(tagString)
+}
+
+// parseTag parses json tag from the raw field tag, e.g. json:"place_type,omitempty".
+// Other tags could precede json tag and contain spaces in their values.
+func parseTag(tagString string) (Tag, error) {
+	t := Tag{}
+	value, ok := reflect.StructTag(tagString).Lookup("json")
+	if !ok {
+		return t, nil
+	}
+	tagValues := strings.Split(value, ",")
+	t.JsonName = tagValues[0]
+	for _, option := range tagValues[1:] {
+		if option == "omitempty" {
+			t.Omitempty = true
+		}
+	}
+	return t, nil
+}
+
+// arrayLen returns length of the array type, only integer literals could be evaluated from the syntax.
+func arrayLen(expr ast.Expr) int {
+	if expr == nil {
+		return 0
+	}
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.INT {
+		return ArrayLenUnknown
+	}
+	n, err := strconv.ParseInt(lit.Value, 0, 0)
+	if err != nil {
+		return ArrayLenUnknown
+	}
+	return int(n)
+}
+
+func parseFieldType(t ast.Expr) (Type, error) {
+	switch v := t.(type) {
+	case *ast.Ident:
+		if st := simpleType(v.Name); st != nil {
+			return st, nil
+		}
+		return TypeCustom{Name: v.Name}, nil
+	case *ast.SelectorExpr:
+		return TypeCustom{Name: v.Sel.Name, Expr: t}, nil
+	case *ast.ArrayType:
+		t, err := parseFieldType(v.Elt)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse array nested type %+v", t)
+		}
+		return TypeArray{InnerType: t, Len: arrayLen(v.Len)}, nil
+	case *ast.StarExpr:
+		t, err := parseFieldType(v.X)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse star expr type %+v", t)
+		}
+		return TypePointer{InnerType: t}, nil
+	case *ast.MapType:
+		kt, err := parseFieldType(v.Key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse map key type %+v", v.Key)
+		}
+		vt, er := parseFieldType(v.Value)
+		if er != nil {
+			return nil, errors.Wrapf(er, "failed to parse map value type %+v", v.Value)
+		}
+		return TypeMap{KeyType: kt, ValueType: vt}, nil
+	default:
+		return nil, fmt.Errorf("unexpected %+[1]v with type %[1]T", t)
+	}
+}
+
+// parseFieldNames returns names of the fields declared together, embedded field is named after its type.
+func parseFieldNames(fieldNames []*ast.Ident, fieldType ast.Expr) ([]string, error) {
+	if len(fieldNames) > 0 {
+		names := make([]string, 0, len(fieldNames))
+		for _, name := range fieldNames {
+			names = append(names, name.Name)
+		}
+		return names, nil
+	}
+
+	if star, ok := fieldType.(*ast.StarExpr); ok {
+		fieldType = star.X
+	}
+	if name := typeName(fieldType); name != "" {
+		return []string{name}, nil
+	}
+	return nil, fmt.Errorf("unexpected embedded field type %+[1]v: %[1]T", fieldType)
+}
+
+func simpleType(fieldType string) Type {
+	switch fieldType {
+	case "string", "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "uintptr",
+		"float32", "float64", "complex64", "complex128", "bool", "byte", "rune":
+		return TypeSimple{Name: fieldType}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,59 @@
+package astparser
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportName(t *testing.T) {
+	for path, name := range map[string]string{
+		"junolab.net/lib_api/timeapi":   "timeapi",
+		"github.com/acme/money/v2":      "money",
+		"gopkg.in/yaml.v3":              "yaml",
+		"github.com/acme/v2":            "acme",
+		"github.com/acme/v2/conv":       "conv",
+		"github.com/acme/semver.v1/x10": "x10",
+		"v2":                            "v2",
+	} {
+		assert.Equal(t, name, importName(path), path)
+	}
+}
+
+func TestWalker_VersionedImports(t *testing.T) {
+	src := `package rides
+
+import (
+	"github.com/acme/money/v2"
+	"gopkg.in/guregu/null.v4"
+	ts "junolab.net/lib_api/timeapi/v3"
+)
+
+const StatusDefault money.Currency = "USD"
+
+type Ride struct {
+	Price    money.Amount ` + "`json:\"price\"`" + `
+	Comment  null.String  ` + "`json:\"comment\"`" + `
+	Finished ts.Time      ` + "`json:\"finished\"`" + `
+}
+`
+	file, err := parser.ParseFile(token.NewFileSet(), "rides.go", src, parser.ParseComments)
+	require.NoError(t, err)
+
+	w := &Walker{}
+	ast.Walk(w, file)
+	require.Empty(t, w.Errors)
+	require.Len(t, w.Structs, 1)
+
+	var packages []string
+	for _, f := range w.Structs[0].Fields {
+		packages = append(packages, f.FieldType.(TypeCustom).Package)
+	}
+	assert.Equal(t, []string{"github.com/acme/money/v2", "gopkg.in/guregu/null.v4", "junolab.net/lib_api/timeapi/v3"}, packages)
+	require.Len(t, w.Constants, 1)
+	assert.Equal(t, "github.com/acme/money/v2", w.Constants[0].TypePackage)
+}
@@ -0,0 +1,251 @@
+package avro
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CanonicalForm returns Parsing Canonical Form of the avro schema as defined by the specification:
+// names are replaced with full names, only attributes relevant for parsing are kept in the fixed order,
+// logical types are reduced to their underlying types and whitespace is removed.
+// Schema must be self-contained, named types are resolved in the namespace.
+func CanonicalForm(schema interface{}, namespace string) ([]byte, error) {
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+	var parsed interface{}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	if err := writeCanonical(&b, parsed, namespace); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func writeCanonical(b *bytes.Buffer, schema interface{}, namespace string) error {
+	switch v := schema.(type) {
+	case string:
+		if !avroIsPrimitiveType(v) {
+			v = fullName(v, namespace)
+		}
+		writeCanonicalString(b, v)
+		return nil
+	case []interface{}:
+		b.WriteString("[")
+		for i, u := range v {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			if err := writeCanonical(b, u, namespace); err != nil {
+				return err
+			}
+		}
+		b.WriteString("]")
+		return nil
+	case map[string]interface{}:
+		return writeCanonicalObject(b, v, namespace)
+	default:
+		return fmt.Errorf("unexpected schema %v", schema)
+	}
+}
+
+func writeCanonicalObject(b *bytes.Buffer, schema map[string]interface{}, namespace string) error {
+	t, ok := schema["type"].(string)
+	if !ok {
+		// {"type": {"type": "array", ...}} is the nested schema itself
+		return writeCanonical(b, schema["type"], namespace)
+	}
+
+	switch t {
+	case "record", "error", "enum", "fixed":
+		name, _ := schema["name"].(string)
+		if ns, ok := schema["namespace"].(string); ok && !strings.Contains(name, ".") {
+			namespace = ns
+		}
+		name = fullName(name, namespace)
+		namespace = ""
+		if i := strings.LastIndex(name, "."); i >= 0 {
+			namespace = name[:i]
+		}
+
+		b.WriteString(`{"name":`)
+		writeCanonicalString(b, name)
+		b.WriteString(`,"type":`)
+		writeCanonicalString(b, t)
+		switch t {
+		case "enum":
+			b.WriteString(`,"symbols":[`)
+			symbols, _ := schema["symbols"].([]interface{})
+			for i, s := range symbols {
+				if i > 0 {
+					b.WriteString(",")
+				}
+				writeCanonicalString(b, fmt.Sprint(s))
+			}
+			b.WriteString("]")
+		case "fixed":
+			size, _ := schema["size"].(float64)
+			b.WriteString(`,"size":` + strconv.FormatInt(int64(size), 10))
+		default:
+			b.WriteString(`,"fields":[`)
+			fields, _ := schema["fields"].([]interface{})
+			for i, f := range fields {
+				field, _ := f.(map[string]interface{})
+				if i > 0 {
+					b.WriteString(",")
+				}
+				b.WriteString(`{"name":`)
+				writeCanonicalString(b, fmt.Sprint(field["name"]))
+				b.WriteString(`,"type":`)
+				if err := writeCanonical(b, field["type"], namespace); err != nil {
+					return err
+				}
+				b.WriteString("}")
+			}
+			b.WriteString("]")
+		}
+		b.WriteString("}")
+	case "array":
+		b.WriteString(`{"type":"array","items":`)
+		if err := writeCanonical(b, schema["items"], namespace); err != nil {
+			return err
+		}
+		b.WriteString("}")
+	case "map":
+		b.WriteString(`{"type":"map","values":`)
+		if err := writeCanonical(b, schema["values"], namespace); err != nil {
+			return err
+		}
+		b.WriteString("}")
+	default:
+		// primitive type with attributes, e.g. logical type
+		if !avroIsPrimitiveType(t) {
+			return fmt.Errorf("unexpected type %s", t)
+		}
+		writeCanonicalString(b, t)
+	}
+	return nil
+}
+
+// writeCanonicalString writes json string without escaping of html characters.
+func writeCanonicalString(b *bytes.Buffer, s string) {
+	enc := json.NewEncoder(b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	// encoder terminates the value with new line
+	b.Truncate(b.Len() - 1)
+}
+
+// fullName returns name qualified with namespace unless it is already full.
+func fullName(name, namespace string) string {
+	if strings.Contains(name, ".") || namespace == "" {
+		return name
+	}
+	return namespace + "." + name
+}
+
+// Fingerprints are fingerprints of the schema Parsing Canonical Form.
+// CRC64 is CRC-64-AVRO (Rabin) fingerprint, the single object encoding writes it in little-endian order.
+type Fingerprints struct {
+	CanonicalForm string `json:"canonicalForm"`
+	CRC64         string `json:"crc64"`
+	MD5           string `json:"md5"`
+	SHA256        string `json:"sha256"`
+}
+
+// Fingerprint computes fingerprints of the schema Parsing Canonical Form, CRC64 is the hex of 64-bit value.
+func Fingerprint(schema interface{}, namespace string) (Fingerprints, error) {
+	canonical, err := CanonicalForm(schema, namespace)
+	if err != nil {
+		return Fingerprints{}, err
+	}
+	return Fingerprints{
+		CanonicalForm: string(canonical),
+		CRC64:         fmt.Sprintf("%016x", rabinFingerprint(canonical)),
+		MD5:           fmt.Sprintf("%x", md5.Sum(canonical)),
+		SHA256:        fmt.Sprintf("%x", sha256.Sum256(canonical)),
+	}, nil
+}
+
+// rabinEmpty is the CRC-64-AVRO fingerprint of the empty input.
+const rabinEmpty uint64 = 0xc15d213aa4d7a795
+
+var rabinTable = func() [256]uint64 {
+	var table [256]uint64
+	for i := range table {
+		fp := uint64(i)
+		for j := 0; j < 8; j++ {
+			fp = (fp >> 1) ^ (rabinEmpty & -(fp & 1))
+		}
+		table[i] = fp
+	}
+	return table
+}()
+
+func rabinFingerprint(data []byte) uint64 {
+	fp := rabinEmpty
+	for _, b := range data {
+		fp = (fp >> 8) ^ rabinTable[byte(fp)^b]
+	}
+	return fp
+}
+
+// ProtocolFingerprints computes fingerprints of every named type of the protocol by its full name.
+// Types are made self-contained with dependencies defined inline at their first use.
+func ProtocolFingerprints(p Protocol) (map[string]Fingerprints, error) {
+	deps := map[string]dep{}
+	var names []string
+	register := func(name string, schema interface{}) {
+		if _, ok := deps[name]; !ok {
+			names = append(names, name)
+		}
+		deps[name] = dep{schema: schema}
+	}
+	for _, tpe := range p.Types {
+		namedTypes(qualifiedNames(tpe, p.Namespace), register)
+	}
+	sort.Strings(names)
+
+	result := make(map[string]Fingerprints, len(names))
+	for _, name := range names {
+		schema := deps[name].schema
+		if r, ok := schema.(Record); ok {
+			inline := map[string]bool{}
+			for other := range deps {
+				inline[other] = other != name
+			}
+			schema = inlineRecord(r, deps, inline)
+		}
+
+		fingerprints, err := Fingerprint(schema, p.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		result[name] = fingerprints
+	}
+	return result, nil
+}
+
+// FingerprintsManifest computes fingerprints of the named types of all the protocols by protocol name,
+// it is written to fingerprints.json by genavro.
+func FingerprintsManifest(protocols map[string]Protocol) (map[string]map[string]Fingerprints, error) {
+	manifest := make(map[string]map[string]Fingerprints, len(protocols))
+	for name, p := range protocols {
+		fingerprints, err := ProtocolFingerprints(p)
+		if err != nil {
+			return nil, fmt.Errorf("protocol %s: %v", name, err)
+		}
+		manifest[name] = fingerprints
+	}
+	return manifest, nil
+}
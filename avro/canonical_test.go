@@ -0,0 +1,84 @@
+package avro
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/gojuno/genavro/astparser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalForm(t *testing.T) {
+	schema := Record{
+		Type:      "record",
+		Name:      "Ride",
+		Namespace: "junolab.net",
+		Doc:       "ride <&>",
+		Fields: []Field{
+			{Name: "id", Type: newLogicalType(logicalUUID), Doc: "id"},
+			{Name: "status", Type: Enum{Type: "enum", Name: "Status", Symbols: []string{"a", "b"}, Default: "a"}},
+			{Name: "prev", Type: newUnion("Status"), Default: nullDefault, Order: "ignore"},
+			{Name: "hash", Type: Fixed{Type: "fixed", Name: "other.Hash", Size: 16}},
+			{Name: "tags", Type: Map{Type: "map", Values: Array{Type: "array", Items: "Hash"}}},
+		},
+	}
+
+	got, err := CanonicalForm(schema, "")
+	require.NoError(t, err)
+	assert.Equal(t, `{"name":"junolab.net.Ride","type":"record","fields":[`+
+		`{"name":"id","type":"string"},`+
+		`{"name":"status","type":{"name":"junolab.net.Status","type":"enum","symbols":["a","b"]}},`+
+		`{"name":"prev","type":["null","junolab.net.Status"]},`+
+		`{"name":"hash","type":{"name":"other.Hash","type":"fixed","size":16}},`+
+		`{"name":"tags","type":{"type":"map","values":{"type":"array","items":"junolab.net.Hash"}}}]}`, string(got))
+}
+
+func TestFingerprint(t *testing.T) {
+	// fingerprints of avro specification test data, CRC-64-AVRO values are signed in java
+	for schema, crc64 := range map[string]int64{
+		"null":    7195948357588979594,
+		"boolean": -6970731678124411036,
+		"int":     8247732601305521295,
+		"long":    -3434872931120570953,
+		"float":   5583340709985441680,
+		"double":  -8181574048448539266,
+		"bytes":   5746618253357095269,
+		"string":  -8142146995180207161,
+	} {
+		canonical, err := CanonicalForm(schema, "")
+		require.NoError(t, err)
+		assert.Equal(t, uint64(crc64), rabinFingerprint(canonical), schema)
+	}
+
+	fingerprints, err := Fingerprint("int", "")
+	require.NoError(t, err)
+	assert.Equal(t, Fingerprints{
+		CanonicalForm: `"int"`,
+		CRC64:         "7275d51a3f395c8f",
+		MD5:           "ef524ea1b91e73173d938ade36c1db32",
+		SHA256:        "3f2b87a9fe7cc9b13835598c3981cd45e3e355309e5090aa0933d7becb6fba45",
+	}, fingerprints)
+}
+
+func TestFingerprintsManifest(t *testing.T) {
+	cfg := astparser.Config{
+		InputDir:      "fixtures_test",
+		IncludeRegexp: "test.go",
+	}
+	sources, err := astparser.Load(cfg)
+	require.NoError(t, err)
+
+	manifest, err := FingerprintsManifest(Generate(sources, "junolab.net"))
+	require.NoError(t, err)
+	for name, fingerprints := range manifest {
+		assert.Contains(t, fingerprints, "junolab.net."+name)
+	}
+
+	got, err := json.MarshalIndent(manifest, "", "    ")
+	require.NoError(t, err)
+	want, err := ioutil.ReadFile("fixtures_test/fingerprints.json")
+	require.NoError(t, err)
+	assert.Equal(t, string(want), string(got))
+}
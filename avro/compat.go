@@ -0,0 +1,457 @@
+package avro
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// CompatibilityFull means old and new schemas read data written with each other.
+	CompatibilityFull = "FULL"
+	// CompatibilityBackward means new schema reads data written with the old one.
+	CompatibilityBackward = "BACKWARD"
+	// CompatibilityForward means old schema reads data written with the new one.
+	CompatibilityForward = "FORWARD"
+	// CompatibilityNone means neither schema reads data written with the other one.
+	CompatibilityNone = "NONE"
+)
+
+// Incompatibility describes why the reader schema could not read data written with the writer schema.
+// Path is a path of the field from the root record, e.g. RideV1.payload.stops[].lat,
+// array items are marked with [] and map values with {}.
+type Incompatibility struct {
+	Path    string
+	Message string
+}
+
+func (i Incompatibility) String() string {
+	return i.Path + ": " + i.Message
+}
+
+// Compatibility is a result of comparing old and new versions of the event protocol.
+type Compatibility struct {
+	// Backward are problems of reading old data with the new schema.
+	Backward []Incompatibility
+	// Forward are problems of reading new data with the old schema.
+	Forward []Incompatibility
+}
+
+// Level returns one of FULL, BACKWARD, FORWARD or NONE.
+func (c Compatibility) Level() string {
+	switch {
+	case len(c.Backward) == 0 && len(c.Forward) == 0:
+		return CompatibilityFull
+	case len(c.Backward) == 0:
+		return CompatibilityBackward
+	case len(c.Forward) == 0:
+		return CompatibilityForward
+	default:
+		return CompatibilityNone
+	}
+}
+
+// CompareProtocols checks compatibility of the new version of the event protocol with the old one.
+func CompareProtocols(oldProtocol, newProtocol Protocol) (Compatibility, error) {
+	backward, err := CheckCompatibility(newProtocol, oldProtocol)
+	if err != nil {
+		return Compatibility{}, err
+	}
+	forward, err := CheckCompatibility(oldProtocol, newProtocol)
+	if err != nil {
+		return Compatibility{}, err
+	}
+	return Compatibility{Backward: backward, Forward: forward}, nil
+}
+
+// CheckCompatibility checks if data written with the writer protocol could be read with the reader one
+// following avro schema resolution rules. The last types of the protocols, their envelope records, are compared.
+// Protocols could be generated or loaded from .avpr files.
+func CheckCompatibility(reader, writer Protocol) ([]Incompatibility, error) {
+	r, err := newResolvingSchema(reader)
+	if err != nil {
+		return nil, fmt.Errorf("reader: %v", err)
+	}
+	w, err := newResolvingSchema(writer)
+	if err != nil {
+		return nil, fmt.Errorf("writer: %v", err)
+	}
+
+	c := &compatChecker{reader: r, writer: w, checking: map[[2]string]bool{}}
+	c.check(r.root, w.root, schemaName(r.root), r.namespace, w.namespace)
+	sort.SliceStable(c.incompatibilities, func(i, j int) bool {
+		return c.incompatibilities[i].Path < c.incompatibilities[j].Path
+	})
+	return c.incompatibilities, nil
+}
+
+// resolvingSchema is a protocol parsed to json values with named types by full name.
+type resolvingSchema struct {
+	root      interface{}
+	namespace string
+	named     map[string]map[string]interface{}
+}
+
+func newResolvingSchema(p Protocol) (*resolvingSchema, error) {
+	data, err := json.Marshal(p.Types)
+	if err != nil {
+		return nil, err
+	}
+	var types []interface{}
+	if err := json.Unmarshal(data, &types); err != nil {
+		return nil, err
+	}
+	if len(types) == 0 {
+		return nil, fmt.Errorf("protocol %s has no types", p.Protocol)
+	}
+
+	s := &resolvingSchema{root: types[len(types)-1], namespace: p.Namespace, named: map[string]map[string]interface{}{}}
+	for _, t := range types {
+		s.register(t, p.Namespace)
+	}
+	return s, nil
+}
+
+// register adds named types defined in the schema at any depth.
+func (s *resolvingSchema) register(schema interface{}, namespace string) {
+	switch v := schema.(type) {
+	case []interface{}:
+		for _, u := range v {
+			s.register(u, namespace)
+		}
+	case map[string]interface{}:
+		switch v["type"] {
+		case "record", "error", "enum", "fixed":
+			name := definedName(v, namespace)
+			s.named[name] = v
+			namespace = namespaceOf(name)
+		case "array":
+			s.register(v["items"], namespace)
+		case "map":
+			s.register(v["values"], namespace)
+		default:
+			s.register(v["type"], namespace)
+		}
+		if fields, ok := v["fields"].([]interface{}); ok {
+			for _, f := range fields {
+				if field, ok := f.(map[string]interface{}); ok {
+					s.register(field["type"], namespace)
+				}
+			}
+		}
+	}
+}
+
+// resolve returns definition of the named type and the namespace its names are resolved in,
+// primitive types are returned by name and logical types are reduced to the underlying types.
+func (s *resolvingSchema) resolve(schema interface{}, namespace string) (interface{}, string) {
+	switch v := schema.(type) {
+	case string:
+		if avroIsPrimitiveType(v) {
+			return v, namespace
+		}
+		name := fullName(v, namespace)
+		if def, ok := s.named[name]; ok {
+			return def, namespaceOf(name)
+		}
+		if def, ok := s.named[v]; ok {
+			return def, namespaceOf(v)
+		}
+		return schema, namespace
+	case map[string]interface{}:
+		switch t := v["type"].(type) {
+		case string:
+			if avroIsPrimitiveType(t) {
+				return t, namespace
+			}
+			if t == "record" || t == "error" || t == "enum" || t == "fixed" {
+				return v, namespaceOf(definedName(v, namespace))
+			}
+		case map[string]interface{}, []interface{}:
+			return s.resolve(t, namespace)
+		}
+	}
+	return schema, namespace
+}
+
+// label describes the schema with full names of the named types it refers, union branches are resolved one by one.
+func (s *resolvingSchema) label(schema interface{}, namespace string) string {
+	if branches, ok := schema.([]interface{}); ok {
+		labels := make([]string, 0, len(branches))
+		for _, u := range branches {
+			labels = append(labels, s.label(u, namespace))
+		}
+		return "[" + strings.Join(labels, ", ") + "]"
+	}
+	resolved, ns := s.resolve(schema, namespace)
+	return typeLabel(resolved, ns)
+}
+
+type compatChecker struct {
+	reader, writer    *resolvingSchema
+	incompatibilities []Incompatibility
+	// checking contains pairs of named types being checked to stop on recursive types.
+	checking map[[2]string]bool
+}
+
+func (c *compatChecker) fail(path, format string, args ...interface{}) {
+	c.incompatibilities = append(c.incompatibilities, Incompatibility{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// check reports incompatibilities of reading data written with writer schema by reader schema.
+func (c *compatChecker) check(reader, writer interface{}, path, readerNS, writerNS string) {
+	reader, readerNS = c.reader.resolve(reader, readerNS)
+	writer, writerNS = c.writer.resolve(writer, writerNS)
+
+	// every branch of the writer union could be written
+	if branches, ok := writer.([]interface{}); ok {
+		for _, branch := range branches {
+			c.check(reader, branch, path, readerNS, writerNS)
+		}
+		return
+	}
+	// the first matching branch of the reader union reads the value
+	if branches, ok := reader.([]interface{}); ok {
+		var candidates [][]Incompatibility
+		for _, branch := range branches {
+			found := c.probe(branch, writer, path, readerNS, writerNS)
+			if len(found) == 0 {
+				return
+			}
+			if c.sameType(branch, writer, readerNS, writerNS) {
+				candidates = append(candidates, found)
+			}
+		}
+		// the only branch of the writer type or name is the one the value is meant for, report why it does not read it
+		if len(candidates) == 1 {
+			c.incompatibilities = append(c.incompatibilities, candidates[0]...)
+			return
+		}
+		c.fail(path, "writer type %s is not in reader union %s", typeLabel(writer, writerNS), c.reader.label(reader, readerNS))
+		return
+	}
+
+	readerType, writerType := schemaType(reader), schemaType(writer)
+	if readerType != writerType {
+		if !promotable(writerType, readerType) {
+			c.fail(path, "writer type %s could not be read as %s", typeLabel(writer, writerNS), typeLabel(reader, readerNS))
+		}
+		return
+	}
+
+	r, _ := reader.(map[string]interface{})
+	w, _ := writer.(map[string]interface{})
+	switch readerType {
+	case "record", "error", "enum", "fixed":
+		readerName, writerName := definedName(r, readerNS), definedName(w, writerNS)
+		if !namesMatch(r, readerName, writerName) {
+			c.fail(path, "writer %s %s does not match reader %s %s", writerType, writerName, readerType, readerName)
+			return
+		}
+		key := [2]string{readerName, writerName}
+		if c.checking[key] {
+			return
+		}
+		c.checking[key] = true
+		defer delete(c.checking, key)
+
+		switch readerType {
+		case "enum":
+			c.checkEnum(r, w, path)
+		case "fixed":
+			if r["size"] != w["size"] {
+				c.fail(path, "writer fixed %s size %v does not match reader size %v", writerName, w["size"], r["size"])
+			}
+		default:
+			c.checkRecord(r, w, path, namespaceOf(readerName), namespaceOf(writerName))
+		}
+	case "array":
+		c.check(r["items"], w["items"], path+"[]", readerNS, writerNS)
+	case "map":
+		c.check(r["values"], w["values"], path+"{}", readerNS, writerNS)
+	}
+}
+
+// sameType checks if reader branch is of the resolved writer type, named types are matched by name.
+func (c *compatChecker) sameType(reader, writer interface{}, readerNS, writerNS string) bool {
+	reader, readerNS = c.reader.resolve(reader, readerNS)
+	if schemaType(reader) != schemaType(writer) {
+		return false
+	}
+	r, isNamed := reader.(map[string]interface{})
+	switch schemaType(reader) {
+	case "record", "error", "enum", "fixed":
+		w, _ := writer.(map[string]interface{})
+		return isNamed && namesMatch(r, definedName(r, readerNS), definedName(w, writerNS))
+	default:
+		return true
+	}
+}
+
+// probe checks reader branch against writer type without reporting.
+func (c *compatChecker) probe(reader, writer interface{}, path, readerNS, writerNS string) []Incompatibility {
+	saved := c.incompatibilities
+	c.incompatibilities = nil
+	c.check(reader, writer, path, readerNS, writerNS)
+	found := c.incompatibilities
+	c.incompatibilities = saved
+	return found
+}
+
+func (c *compatChecker) checkRecord(reader, writer map[string]interface{}, path, readerNS, writerNS string) {
+	writerFields := map[string]map[string]interface{}{}
+	for _, f := range fieldsOf(writer) {
+		writerFields[fmt.Sprint(f["name"])] = f
+	}
+
+	for _, rf := range fieldsOf(reader) {
+		name := fmt.Sprint(rf["name"])
+		wf, ok := writerFields[name]
+		if !ok {
+			for _, alias := range stringsOf(rf["aliases"]) {
+				if wf, ok = writerFields[alias]; ok {
+					break
+				}
+			}
+		}
+
+		fieldPath := path + "." + name
+		if !ok {
+			if _, hasDefault := rf["default"]; !hasDefault {
+				c.fail(fieldPath, "field is missing in writer schema and has no default")
+			}
+			continue
+		}
+		c.check(rf["type"], wf["type"], fieldPath, readerNS, writerNS)
+	}
+}
+
+func (c *compatChecker) checkEnum(reader, writer map[string]interface{}, path string) {
+	symbols := map[string]bool{}
+	for _, s := range stringsOf(reader["symbols"]) {
+		symbols[s] = true
+	}
+	if _, hasDefault := reader["default"]; hasDefault {
+		return
+	}
+	var missing []string
+	for _, s := range stringsOf(writer["symbols"]) {
+		if !symbols[s] {
+			missing = append(missing, s)
+		}
+	}
+	if len(missing) > 0 {
+		c.fail(path, "writer symbols %s are not in reader enum %s without default", strings.Join(missing, ", "), reader["name"])
+	}
+}
+
+// promotable checks if writer primitive type is promoted to reader type.
+func promotable(writer, reader string) bool {
+	switch writer {
+	case "int":
+		return reader == "long" || reader == "float" || reader == "double"
+	case "long":
+		return reader == "float" || reader == "double"
+	case "float":
+		return reader == "double"
+	case "string":
+		return reader == "bytes"
+	case "bytes":
+		return reader == "string"
+	}
+	return false
+}
+
+// namesMatch checks if reader named type reads writer one by full name, unqualified name or alias.
+func namesMatch(reader map[string]interface{}, readerName, writerName string) bool {
+	if readerName == writerName || shortName(readerName) == shortName(writerName) {
+		return true
+	}
+	for _, alias := range stringsOf(reader["aliases"]) {
+		if fullName(alias, namespaceOf(readerName)) == writerName || alias == shortName(writerName) {
+			return true
+		}
+	}
+	return false
+}
+
+// schemaType returns type of resolved schema: primitive type name or complex type.
+func schemaType(schema interface{}) string {
+	switch v := schema.(type) {
+	case string:
+		return v
+	case map[string]interface{}:
+		if t, ok := v["type"].(string); ok {
+			return t
+		}
+	}
+	return fmt.Sprintf("%v", schema)
+}
+
+func typeLabel(schema interface{}, namespace string) string {
+	switch v := schema.(type) {
+	case map[string]interface{}:
+		switch t := schemaType(v); t {
+		case "record", "error", "enum", "fixed":
+			return definedName(v, namespace)
+		default:
+			return t
+		}
+	case []interface{}:
+		labels := make([]string, 0, len(v))
+		for _, u := range v {
+			labels = append(labels, typeLabel(u, namespace))
+		}
+		return "[" + strings.Join(labels, ", ") + "]"
+	default:
+		return schemaType(schema)
+	}
+}
+
+// definedName returns full name of the named type definition.
+func definedName(def map[string]interface{}, namespace string) string {
+	name, _ := def["name"].(string)
+	if ns, ok := def["namespace"].(string); ok && !strings.Contains(name, ".") {
+		namespace = ns
+	}
+	return fullName(name, namespace)
+}
+
+func schemaName(schema interface{}) string {
+	if def, ok := schema.(map[string]interface{}); ok {
+		if name, ok := def["name"].(string); ok {
+			return name
+		}
+	}
+	return schemaType(schema)
+}
+
+func namespaceOf(fullName string) string {
+	if i := strings.LastIndex(fullName, "."); i >= 0 {
+		return fullName[:i]
+	}
+	return ""
+}
+
+func fieldsOf(record map[string]interface{}) []map[string]interface{} {
+	list, _ := record["fields"].([]interface{})
+	fields := make([]map[string]interface{}, 0, len(list))
+	for _, f := range list {
+		if field, ok := f.(map[string]interface{}); ok {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+func stringsOf(value interface{}) []string {
+	list, _ := value.([]interface{})
+	result := make([]string, 0, len(list))
+	for _, v := range list {
+		if s, ok := v.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
@@ -0,0 +1,156 @@
+package avro
+
+import (
+	"testing"
+
+	"github.com/gojuno/genavro/astparser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareProtocols(t *testing.T) {
+	status := Enum{Type: "enum", Name: "Status", Symbols: []string{"created", "done"}}
+	point := Record{Type: "record", Name: "Point", Fields: []Field{{Name: "lat", Type: "float"}}}
+	old := Record{Type: "record", Name: "RideV1", Fields: []Field{
+		{Name: "ride_id", Type: "string"},
+		{Name: "count", Type: "int"},
+		{Name: "status", Type: "Status"},
+		{Name: "comment", Type: newUnion("string"), Default: nullDefault},
+		{Name: "stops", Type: Array{Type: "array", Items: "Point"}},
+	}}
+
+	start := Field{Name: "start", Type: newUnion("Point"), Default: nullDefault}
+	for name, tc := range map[string]struct {
+		old      []interface{}
+		new      []interface{}
+		level    string
+		backward []string
+		forward  []string
+	}{
+		"same": {
+			new:   []interface{}{status, point, old},
+			level: CompatibilityFull,
+		},
+		"optional field added": {
+			new:   []interface{}{status, point, withField(old, Field{Name: "tip", Type: newUnion("long"), Default: nullDefault})},
+			level: CompatibilityFull,
+		},
+		"required field added": {
+			new:      []interface{}{status, point, withField(old, Field{Name: "tip", Type: "long"})},
+			level:    CompatibilityForward,
+			backward: []string{"RideV1.tip: field is missing in writer schema and has no default"},
+		},
+		"int promoted to long": {
+			new:     []interface{}{status, point, withFieldType(old, "count", "long")},
+			level:   CompatibilityBackward,
+			forward: []string{"RideV1.count: writer type long could not be read as int"},
+		},
+		"enum symbol added": {
+			new:     []interface{}{Enum{Type: "enum", Name: "Status", Symbols: []string{"created", "done", "failed"}}, point, old},
+			level:   CompatibilityBackward,
+			forward: []string{"RideV1.status: writer symbols failed are not in reader enum Status without default"},
+		},
+		"enum symbol added with default": {
+			new:     []interface{}{Enum{Type: "enum", Name: "Status", Symbols: []string{"created", "done", "failed"}, Default: "created"}, point, old},
+			level:   CompatibilityBackward,
+			forward: []string{"RideV1.status: writer symbols failed are not in reader enum Status without default"},
+		},
+		"field renamed with alias": {
+			new:     []interface{}{status, point, withFieldAlias(old, "ride_id", "trip_id")},
+			level:   CompatibilityBackward,
+			forward: []string{"RideV1.ride_id: field is missing in writer schema and has no default"},
+		},
+		"nullable made required": {
+			new:      []interface{}{status, point, withFieldType(old, "comment", "string")},
+			level:    CompatibilityForward,
+			backward: []string{"RideV1.comment: writer type null could not be read as string"},
+		},
+		"nested field changed": {
+			new:      []interface{}{status, Record{Type: "record", Name: "Point", Fields: []Field{{Name: "lat", Type: "string"}}}, old},
+			level:    CompatibilityNone,
+			backward: []string{"RideV1.stops[].lat: writer type float could not be read as string"},
+			forward:  []string{"RideV1.stops[].lat: writer type string could not be read as float"},
+		},
+		"nested field of nullable record changed": {
+			old:      []interface{}{status, point, withField(old, start)},
+			new:      []interface{}{status, Record{Type: "record", Name: "Point", Fields: []Field{{Name: "lat", Type: "string"}}}, withField(old, start)},
+			level:    CompatibilityNone,
+			backward: []string{"RideV1.start.lat: writer type float could not be read as string", "RideV1.stops[].lat: writer type float could not be read as string"},
+			forward:  []string{"RideV1.start.lat: writer type string could not be read as float", "RideV1.stops[].lat: writer type string could not be read as float"},
+		},
+		"nullable record replaced": {
+			old:      []interface{}{status, point, withField(old, start)},
+			new:      []interface{}{status, point, withField(old, Field{Name: "start", Type: newUnion("Status"), Default: nullDefault})},
+			level:    CompatibilityNone,
+			backward: []string{"RideV1.start: writer type junolab.net.Point is not in reader union [null, junolab.net.Status]"},
+			forward:  []string{"RideV1.start: writer type junolab.net.Status is not in reader union [null, junolab.net.Point]"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if tc.old == nil {
+				tc.old = []interface{}{status, point, old}
+			}
+			c, err := CompareProtocols(
+				Protocol{Namespace: "junolab.net", Protocol: "RideV1", Types: tc.old},
+				Protocol{Namespace: "junolab.net", Protocol: "RideV1", Types: tc.new},
+			)
+			require.NoError(t, err)
+			assert.Equal(t, tc.level, c.Level())
+			assert.Equal(t, tc.backward, nilIfEmpty(incompatibilityStrings(c.Backward)))
+			assert.Equal(t, tc.forward, nilIfEmpty(incompatibilityStrings(c.Forward)))
+		})
+	}
+}
+
+func TestCompareProtocols_Generated(t *testing.T) {
+	cfg := astparser.Config{
+		InputDir:      "fixtures_test",
+		IncludeRegexp: "test.go",
+	}
+	sources, err := astparser.Load(cfg)
+	require.NoError(t, err)
+
+	// recursive types are checked once
+	for name, protocol := range Generate(sources, "junolab.net") {
+		c, err := CompareProtocols(protocol, protocol)
+		require.NoError(t, err)
+		assert.Equal(t, CompatibilityFull, c.Level(), name)
+	}
+}
+
+func withField(r Record, f Field) Record {
+	r.Fields = append(append([]Field{}, r.Fields...), f)
+	return r
+}
+
+func withFieldType(r Record, name string, t interface{}) Record {
+	fields := make([]Field, 0, len(r.Fields))
+	for _, f := range r.Fields {
+		if f.Name == name {
+			f.Type, f.Default = t, nil
+		}
+		fields = append(fields, f)
+	}
+	r.Fields = fields
+	return r
+}
+
+func withFieldAlias(r Record, name, newName string) Record {
+	fields := make([]Field, 0, len(r.Fields))
+	for _, f := range r.Fields {
+		if f.Name == name {
+			f.Name, f.Aliases = newName, []string{name}
+		}
+		fields = append(fields, f)
+	}
+	r.Fields = fields
+	return r
+}
+
+func incompatibilityStrings(incompatibilities []Incompatibility) []string {
+	result := make([]string, 0, len(incompatibilities))
+	for _, i := range incompatibilities {
+		result = append(result, i.String())
+	}
+	return result
+}
@@ -0,0 +1,191 @@
+package avro
+
+import (
+	"fmt"
+	"go/token"
+	"sort"
+	"strings"
+
+	"github.com/gojuno/genavro/astparser"
+)
+
+// typeDecl is a struct or named type declared in the sources.
+type typeDecl struct {
+	name string
+	pkg  string
+	pos  token.Position
+	// shape describes the declaration without positions and comments, equal shapes generate equal schemas.
+	shape string
+}
+
+// detectConflicts finds structs and named types with the same name and different shapes,
+// e.g. two Location structs from different packages which would overwrite each other in the protocol.
+// Conflicts are reported at every declaration, with DisambiguateNamespaces the types declared
+// in other packages are named with the namespace of their package instead, see declaredName.
+func (g *generator) detectConflicts(sources map[string]astparser.ParsedFile, files []string) {
+	byName := map[string][]typeDecl{}
+	var names []string
+	add := func(d typeDecl) {
+		if _, ok := byName[d.name]; !ok {
+			names = append(names, d.name)
+		}
+		byName[d.name] = append(byName[d.name], d)
+	}
+	for _, file := range files {
+		parsedFile := sources[file]
+		for _, s := range parsedFile.Structs {
+			add(typeDecl{name: s.Name, pkg: parsedFile.Package, pos: s.Pos, shape: structShape(s)})
+		}
+		for _, t := range parsedFile.Types {
+			add(typeDecl{name: t.Name, pkg: parsedFile.Package, pos: t.Pos, shape: "type " + typeShape(t.Type)})
+		}
+	}
+
+	for _, name := range names {
+		decls := byName[name]
+		if !conflicting(decls) {
+			continue
+		}
+		if g.opts.DisambiguateNamespaces && !g.isEvent(name) && onePerPackage(decls) {
+			for _, d := range decls {
+				// types of the parsed package keep the namespace of the protocol
+				if d.pkg != "" {
+					g.names[qualifiedTypeName(astparser.TypeCustom{Name: name, Package: d.pkg})] = packageNamespace(d.pkg) + "." + name
+				}
+			}
+			continue
+		}
+
+		for i, d := range decls {
+			var others []string
+			for j, other := range decls {
+				if j != i && other.shape != d.shape {
+					others = append(others, other.pos.String())
+				}
+			}
+			if len(others) == 0 {
+				continue
+			}
+			g.errorf(d.pos, name, "", "type %s conflicts with the type of the same name declared at %s", name, strings.Join(others, ", "))
+		}
+	}
+}
+
+// withDeclaredNames returns copy of the parsed file with structs and named types named by their avro names,
+// constants keep go names of their types.
+func (g *generator) withDeclaredNames(parsedFile astparser.ParsedFile) astparser.ParsedFile {
+	if len(g.names) == 0 || parsedFile.Package == "" {
+		return parsedFile
+	}
+
+	structs := make([]astparser.StructDef, 0, len(parsedFile.Structs))
+	for _, s := range parsedFile.Structs {
+		s.Name = g.declaredName(s.Name, parsedFile.Package)
+		structs = append(structs, s)
+	}
+	types := make([]astparser.TypeDef, 0, len(parsedFile.Types))
+	for _, t := range parsedFile.Types {
+		t.Name = g.declaredName(t.Name, parsedFile.Package)
+		types = append(types, t)
+	}
+
+	parsedFile.Structs, parsedFile.Types = structs, types
+	return parsedFile
+}
+
+// declaredName returns avro name of the struct or named type declared in the package.
+func (g *generator) declaredName(name, pkg string) string {
+	return g.typeName(astparser.TypeCustom{Name: name, Package: pkg})
+}
+
+// typeName returns avro name of the struct or named type the custom type refers to.
+func (g *generator) typeName(t astparser.TypeCustom) string {
+	if name, ok := g.names[qualifiedTypeName(t)]; ok {
+		return name
+	}
+	return t.Name
+}
+
+// conflicting checks if declarations of the same name have different shapes.
+func conflicting(decls []typeDecl) bool {
+	for _, d := range decls[1:] {
+		if d.shape != decls[0].shape {
+			return true
+		}
+	}
+	return false
+}
+
+// onePerPackage checks if every package declares only one shape of the type,
+// types declared in one package could not be told apart by namespace.
+func onePerPackage(decls []typeDecl) bool {
+	shapes := map[string]string{}
+	for _, d := range decls {
+		if shape, ok := shapes[d.pkg]; ok && shape != d.shape {
+			return false
+		}
+		shapes[d.pkg] = d.shape
+	}
+	return true
+}
+
+// packageNamespace converts go import path to avro namespace,
+// e.g. github.com/gojuno/geo-api becomes github_com.gojuno.geo_api.
+func packageNamespace(pkg string) string {
+	parts := strings.Split(pkg, "/")
+	for i, part := range parts {
+		part = strings.Map(func(r rune) rune {
+			if r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+				return r
+			}
+			return '_'
+		}, part)
+		if part == "" || part[0] >= '0' && part[0] <= '9' {
+			part = "_" + part
+		}
+		parts[i] = part
+	}
+	return strings.Join(parts, ".")
+}
+
+func structShape(s astparser.StructDef) string {
+	fields := make([]string, 0, len(s.Fields))
+	for _, f := range s.Fields {
+		fields = append(fields, fmt.Sprintf("%s %s %q %t %t %q", f.FieldName, typeShape(f.FieldType), f.JsonName, f.Omitempty, f.Embedded, f.Tag))
+	}
+	return "struct {" + strings.Join(fields, "; ") + "}"
+}
+
+// typeShape returns go type with fully qualified names of the custom types.
+func typeShape(t astparser.Type) string {
+	switch v := t.(type) {
+	case astparser.TypeSimple:
+		return v.Name
+	case astparser.TypePointer:
+		return "*" + typeShape(v.InnerType)
+	case astparser.TypeArray:
+		if v.Len == 0 {
+			return "[]" + typeShape(v.InnerType)
+		}
+		return fmt.Sprintf("[%d]%s", v.Len, typeShape(v.InnerType))
+	case astparser.TypeMap:
+		return "map[" + typeShape(v.KeyType) + "]" + typeShape(v.ValueType)
+	case astparser.TypeCustom:
+		return qualifiedTypeName(v)
+	case astparser.TypeUnsupported:
+		return v.Name
+	default:
+		return fmt.Sprintf("%T", t)
+	}
+}
+
+// sortedFiles returns names of the parsed files in order,
+// the first declaration wins if conflicting types are not disambiguated.
+func sortedFiles(sources map[string]astparser.ParsedFile) []string {
+	files := make([]string, 0, len(sources))
+	for file := range sources {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files
+}
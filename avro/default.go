@@ -0,0 +1,162 @@
+package avro
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// defaultCommentPrefix marks the comment which sets default value of the field or enum,
+// e.g. `// avro:default=active`.
+const defaultCommentPrefix = "avro:default="
+
+// nullDefault is a default of nullable fields.
+var nullDefault = json.RawMessage("null")
+
+// commentDefault splits default value from the comments, the rest of comments is a doc.
+func commentDefault(comments []string) (doc []string, value string, ok bool) {
+	for _, c := range comments {
+		if strings.HasPrefix(c, defaultCommentPrefix) {
+			value, ok = strings.TrimPrefix(c, defaultCommentPrefix), true
+			continue
+		}
+		doc = append(doc, c)
+	}
+	return doc, value, ok
+}
+
+// withDefault sets default of the field.
+// Nullable fields default to null, avro requires default to match the first union type,
+// so union is reordered if nullable field has not null default.
+func withDefault(field Field, value json.RawMessage) Field {
+	u, isUnion := field.Type.(Union)
+	if !isUnion || u[0] != "null" {
+		field.Default = value
+		return field
+	}
+
+	if len(value) == 0 || bytes.Equal(value, nullDefault) {
+		field.Default = nullDefault
+		return field
+	}
+	field.Type = Union{u[1], u[0]}
+	field.Default = value
+	return field
+}
+
+// checkDefault checks if default json value matches avro type.
+func (g *generator) checkDefault(t interface{}, value json.RawMessage) error {
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(value))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return fmt.Errorf("invalid default %s: %v", value, err)
+	}
+
+	if !g.matchesDefault(t, v) {
+		return fmt.Errorf("default %s does not match type %s", value, avroTypeString(t))
+	}
+	return nil
+}
+
+func (g *generator) matchesDefault(t interface{}, v interface{}) bool {
+	switch tpe := t.(type) {
+	case string:
+		return g.matchesNamedDefault(tpe, v)
+	case LogicalType:
+		return g.matchesDefault(tpe.Type, v)
+	case Union:
+		return g.matchesDefault(tpe[0], v)
+	case Array:
+		items, ok := v.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, item := range items {
+			if !g.matchesDefault(tpe.Items, item) {
+				return false
+			}
+		}
+		return true
+	case Map:
+		values, ok := v.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for _, value := range values {
+			if !g.matchesDefault(tpe.Values, value) {
+				return false
+			}
+		}
+		return true
+	default:
+		// schemas from the type map are not checked
+		return true
+	}
+}
+
+func (g *generator) matchesNamedDefault(name string, v interface{}) bool {
+	switch name {
+	case "null":
+		return v == nil
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "int", "long":
+		n, ok := v.(json.Number)
+		if !ok {
+			return false
+		}
+		i, err := n.Int64()
+		if err != nil {
+			return false
+		}
+		return name == "long" || (i >= math.MinInt32 && i <= math.MaxInt32)
+	case "float", "double":
+		n, ok := v.(json.Number)
+		if !ok {
+			return false
+		}
+		_, err := n.Float64()
+		return err == nil
+	case "string", "bytes":
+		_, ok := v.(string)
+		return ok
+	}
+
+	if e, ok := g.enums[name]; ok {
+		symbol, ok := v.(string)
+		if !ok {
+			return false
+		}
+		for _, s := range e.Symbols {
+			if s == symbol {
+				return true
+			}
+		}
+		return false
+	}
+	if _, ok := g.structs[name]; ok {
+		_, ok := v.(map[string]interface{})
+		return ok
+	}
+	if f, ok := g.deps[name].schema.(Fixed); ok {
+		s, ok := v.(string)
+		return ok && len([]rune(s)) == f.Size
+	}
+	return true
+}
+
+// avroTypeString formats avro type as json for messages.
+func avroTypeString(t interface{}) string {
+	if s, ok := t.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(t)
+	if err != nil {
+		return fmt.Sprint(t)
+	}
+	return string(b)
+}
@@ -0,0 +1,258 @@
+package avro
+
+import (
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// requiredRef is a non-nullable field of the record which refers other record directly,
+// it could not be empty unlike nullable, array or map field.
+type requiredRef struct {
+	name  string
+	field string
+	pos   token.Position
+}
+
+// depGraph is a graph of named types shared by all protocols.
+// Strongly connected components are found on demand with Tarjan's algorithm and cached,
+// so every type is visited once however many protocols and paths refer to it.
+// Order is deterministic: edges are followed in order of fields.
+type depGraph struct {
+	deps  map[string]dep
+	edges func(name string) []string
+
+	index     map[string]int
+	lowlink   map[string]int
+	onStack   map[string]bool
+	stack     []string
+	component map[string]int
+	// components contain names of the types in order they were reached in.
+	components [][]string
+
+	// successors and declarations are cached by the type the component is entered from.
+	successors   map[string][]string
+	declarations map[string]interface{}
+}
+
+func newDepGraph(deps map[string]dep, edges func(name string) []string) *depGraph {
+	return &depGraph{
+		deps:         deps,
+		edges:        edges,
+		index:        map[string]int{},
+		lowlink:      map[string]int{},
+		onStack:      map[string]bool{},
+		component:    map[string]int{},
+		successors:   map[string][]string{},
+		declarations: map[string]interface{}{},
+	}
+}
+
+// componentOf returns index of the strongly connected component of the type,
+// false if the type is unknown.
+func (gr *depGraph) componentOf(name string) (int, bool) {
+	if _, ok := gr.deps[name]; !ok {
+		return 0, false
+	}
+	if _, visited := gr.index[name]; !visited {
+		gr.visit(name)
+	}
+	return gr.component[name], true
+}
+
+// visit is a step of Tarjan's algorithm, it never revisits types of already found components.
+func (gr *depGraph) visit(name string) {
+	gr.index[name] = len(gr.index)
+	gr.lowlink[name] = gr.index[name]
+	gr.stack = append(gr.stack, name)
+	gr.onStack[name] = true
+
+	for _, next := range gr.edges(name) {
+		if _, ok := gr.deps[next]; !ok {
+			continue
+		}
+		if _, visited := gr.index[next]; !visited {
+			gr.visit(next)
+			if gr.lowlink[next] < gr.lowlink[name] {
+				gr.lowlink[name] = gr.lowlink[next]
+			}
+		} else if gr.onStack[next] && gr.index[next] < gr.lowlink[name] {
+			gr.lowlink[name] = gr.index[next]
+		}
+	}
+
+	if gr.lowlink[name] != gr.index[name] {
+		return
+	}
+
+	// the stack keeps the order types were reached in
+	i := len(gr.stack) - 1
+	for gr.stack[i] != name {
+		i--
+	}
+	component := append([]string{}, gr.stack[i:]...)
+	gr.stack = gr.stack[:i]
+	for _, member := range component {
+		gr.onStack[member] = false
+		gr.component[member] = len(gr.components)
+	}
+	gr.components = append(gr.components, component)
+}
+
+// order returns named types the roots depend on, every type is declared before it is referred by name.
+// Records of the same cycle could not be declared one by one, the one the cycle is entered from is declared
+// with the rest defined inline at their first reference and referred by name after that.
+func (gr *depGraph) order(roots []string) []interface{} {
+	var types []interface{}
+	visited := map[int]bool{}
+
+	var visit func(entry string, c int)
+	visit = func(entry string, c int) {
+		visited[c] = true
+		for _, next := range gr.successorsOf(entry) {
+			if nc, _ := gr.componentOf(next); !visited[nc] {
+				visit(next, nc)
+			}
+		}
+		types = append(types, gr.declaration(entry))
+	}
+
+	for _, root := range roots {
+		if c, ok := gr.componentOf(root); ok && !visited[c] {
+			visit(root, c)
+		}
+	}
+	return types
+}
+
+// successorsOf returns types of other components the component entered from the type refers to,
+// each component is returned once by the first type it is reached by.
+func (gr *depGraph) successorsOf(entry string) []string {
+	if successors, ok := gr.successors[entry]; ok {
+		return successors
+	}
+
+	c, _ := gr.componentOf(entry)
+	seen := map[string]bool{}
+	seenComponents := map[int]bool{c: true}
+	var successors []string
+
+	var walk func(name string)
+	walk = func(name string) {
+		seen[name] = true
+		for _, next := range gr.edges(name) {
+			nc, ok := gr.componentOf(next)
+			switch {
+			case !ok:
+			case nc == c:
+				if !seen[next] {
+					walk(next)
+				}
+			case !seenComponents[nc]:
+				seenComponents[nc] = true
+				successors = append(successors, next)
+			}
+		}
+	}
+	walk(entry)
+
+	gr.successors[entry] = successors
+	return successors
+}
+
+// declaration returns schema of the component entered from the type.
+func (gr *depGraph) declaration(entry string) interface{} {
+	if d, ok := gr.declarations[entry]; ok {
+		return d
+	}
+
+	schema := gr.deps[entry].schema
+	c, _ := gr.componentOf(entry)
+	if component := gr.components[c]; len(component) > 1 {
+		inline := map[string]bool{}
+		for _, name := range component {
+			inline[name] = name != entry
+		}
+		schema = inlineRecord(schema.(Record), gr.deps, inline)
+	}
+
+	gr.declarations[entry] = schema
+	return schema
+}
+
+// inlineRecord copies the record with inline types defined at their first reference.
+// Defined types are removed from inline set, they are referred by name after the definition.
+func inlineRecord(r Record, deps map[string]dep, inline map[string]bool) Record {
+	fields := make([]Field, 0, len(r.Fields))
+	for _, f := range r.Fields {
+		f.Type = inlineType(f.Type, deps, inline)
+		fields = append(fields, f)
+	}
+	r.Fields = fields
+	return r
+}
+
+func inlineType(t interface{}, deps map[string]dep, inline map[string]bool) interface{} {
+	switch v := t.(type) {
+	case string:
+		if !inline[v] {
+			return v
+		}
+		delete(inline, v)
+		if r, ok := deps[v].schema.(Record); ok {
+			return inlineRecord(r, deps, inline)
+		}
+		return deps[v].schema
+	case Array:
+		v.Items = inlineType(v.Items, deps, inline)
+		return v
+	case Map:
+		v.Values = inlineType(v.Values, deps, inline)
+		return v
+	case Union:
+		union := make(Union, 0, len(v))
+		for _, u := range v {
+			union = append(union, inlineType(u, deps, inline))
+		}
+		return union
+	default:
+		return t
+	}
+}
+
+// checkRequiredCycles reports records which refer themselves through non-nullable record fields only,
+// values of such records are infinite and could never be serialized.
+func (g *generator) checkRequiredCycles(deps map[string]dep) {
+	names := make([]string, 0, len(deps))
+	for name := range deps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	gr := newDepGraph(deps, func(name string) []string {
+		var refs []string
+		for _, ref := range deps[name].required {
+			refs = append(refs, ref.name)
+		}
+		return refs
+	})
+
+	for _, name := range names {
+		c, _ := gr.componentOf(name)
+		component := gr.components[c]
+		for _, ref := range deps[name].required {
+			rc, ok := gr.componentOf(ref.name)
+			if !ok || rc != c || len(component) == 1 && ref.name != name {
+				continue
+			}
+			if len(component) == 1 {
+				g.errorf(ref.pos, name, ref.field,
+					"record %s refers to itself through non-nullable field and could never be serialized, make the field nullable", name)
+				continue
+			}
+			g.errorf(ref.pos, name, ref.field,
+				"records %s refer to each other through non-nullable fields only and could never be serialized, make one of the fields nullable",
+				strings.Join(component, ", "))
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package avro
+
+import (
+	"fmt"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// Severity is a severity of the diagnostic.
+type Severity int
+
+const (
+	// SeverityWarning reports suspicious go code which is still generated.
+	SeverityWarning Severity = iota
+	// SeverityError reports go code which could not be generated, e.g. unsupported field type.
+	// Such fields are skipped in the generated schema.
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+}
+
+// Diagnostic describes a problem found during generation.
+// Struct is a name of the struct or named type and Field is a name of its field
+// or constant the problem was found at, both could be empty.
+type Diagnostic struct {
+	Pos      token.Position
+	Struct   string
+	Field    string
+	Severity Severity
+	Message  string
+}
+
+// String formats diagnostic as `file:line:column: Struct.Field: severity: message`.
+func (d Diagnostic) String() string {
+	var parts []string
+	if d.Pos.IsValid() {
+		parts = append(parts, d.Pos.String())
+	}
+
+	switch {
+	case d.Struct != "" && d.Field != "":
+		parts = append(parts, d.Struct+"."+d.Field)
+	case d.Struct != "":
+		parts = append(parts, d.Struct)
+	}
+
+	parts = append(parts, d.Severity.String(), d.Message)
+	return strings.Join(parts, ": ")
+}
+
+// sortDiagnostics orders diagnostics by position, diagnostics at the same position are ordered by text.
+func sortDiagnostics(diagnostics []Diagnostic) {
+	sort.Slice(diagnostics, func(i, j int) bool {
+		pi, pj := diagnostics[i].Pos, diagnostics[j].Pos
+		if pi.Filename != pj.Filename {
+			return pi.Filename < pj.Filename
+		}
+		if pi.Line != pj.Line {
+			return pi.Line < pj.Line
+		}
+		if pi.Column != pj.Column {
+			return pi.Column < pj.Column
+		}
+		return diagnostics[i].String() < diagnostics[j].String()
+	})
+}
+
+// HasErrors checks if any of diagnostics has error severity.
+func HasErrors(diagnostics []Diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,176 @@
+package avro
+
+import (
+	"reflect"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gojuno/genavro/astparser"
+)
+
+// jsonField is a struct field as encoding/json sees it, fields of embedded structs are promoted.
+type jsonField struct {
+	astparser.FieldDef
+	name   string
+	tagged bool
+	// index is a path of field indexes from the top level struct.
+	index []int
+	// nullable marks fields promoted through embedded pointer.
+	nullable bool
+}
+
+// jsonFields flattens embedded structs the way encoding/json does:
+// untagged embedded struct fields are promoted to the struct, tagged ones are regular fields.
+// Promoted field is shadowed by the field with the same name at the shallower depth,
+// fields with the same name at the same depth conflict and are dropped unless only one of them is tagged.
+func (g *generator) jsonFields(s astparser.StructDef) []jsonField {
+	var fields []jsonField
+	g.collectJSONFields(s, nil, false, map[string]bool{s.Name: true}, &fields)
+
+	byName := map[string][]jsonField{}
+	for _, f := range fields {
+		byName[f.name] = append(byName[f.name], f)
+	}
+
+	result := make([]jsonField, 0, len(byName))
+	for _, sameName := range byName {
+		if f, ok := dominantField(sameName); ok {
+			result = append(result, f)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return lessIndex(result[i].index, result[j].index)
+	})
+	return result
+}
+
+func (g *generator) collectJSONFields(s astparser.StructDef, index []int, nullable bool, embedding map[string]bool, fields *[]jsonField) {
+	for i, f := range s.Fields {
+		fieldIndex := append(append([]int{}, index...), i)
+
+		embedded, isPointer, isStruct := g.embeddedStruct(f)
+		if jsonIgnored(f, isStruct) || f.Embedded && g.selector.isMarker(f.FieldType) {
+			continue
+		}
+
+		if f.Embedded && f.JsonName == "" {
+			if isStruct {
+				// embedding cycle is only possible through pointers, json stops there as well
+				if embedding[embedded.Name] {
+					continue
+				}
+				embedding[embedded.Name] = true
+				g.collectJSONFields(embedded, fieldIndex, nullable || isPointer, embedding, fields)
+				delete(embedding, embedded.Name)
+				continue
+			}
+			if !g.isKnownType(f.FieldType) {
+				g.report(SeverityWarning, f.Pos, s.Name, f.FieldName,
+					"embedded type is not found in the sources and is generated as a regular field, try to load sources with go/packages")
+			}
+		}
+
+		name := f.JsonName
+		if name == "" {
+			name = f.FieldName
+			if g.opts.WarnUntagged {
+				g.report(SeverityWarning, f.Pos, s.Name, f.FieldName,
+					"field has no json tag and is named %s after the go field", name)
+			}
+		}
+		*fields = append(*fields, jsonField{
+			FieldDef: f,
+			name:     name,
+			tagged:   f.JsonName != "",
+			index:    fieldIndex,
+			nullable: nullable,
+		})
+	}
+}
+
+// jsonIgnored checks if encoding/json skips the field: it is tagged with `json:"-"` or it is unexported.
+// Fields of unexported embedded structs are still promoted.
+func jsonIgnored(f astparser.FieldDef, isStruct bool) bool {
+	if reflect.StructTag(f.Tag).Get("json") == "-" {
+		return true
+	}
+	if isExported(f.FieldName) {
+		return false
+	}
+	return !f.Embedded || !isStruct
+}
+
+func isExported(name string) bool {
+	r, _ := utf8.DecodeRuneInString(name)
+	return unicode.IsUpper(r)
+}
+
+// embeddedStruct finds the struct of embedded field and reports if it is embedded by pointer.
+func (g *generator) embeddedStruct(f astparser.FieldDef) (astparser.StructDef, bool, bool) {
+	if !f.Embedded {
+		return astparser.StructDef{}, false, false
+	}
+
+	t, isPointer := f.FieldType, false
+	if p, ok := t.(astparser.TypePointer); ok {
+		t, isPointer = p.InnerType, true
+	}
+
+	custom, ok := t.(astparser.TypeCustom)
+	if !ok {
+		return astparser.StructDef{}, false, false
+	}
+
+	s, ok := g.structs[g.typeName(custom)]
+	return s, isPointer, ok
+}
+
+// isKnownType checks if the custom type is declared in the sources or mapped with the type map.
+func (g *generator) isKnownType(t astparser.Type) bool {
+	if p, ok := t.(astparser.TypePointer); ok {
+		t = p.InnerType
+	}
+
+	custom, ok := t.(astparser.TypeCustom)
+	if !ok {
+		return true
+	}
+	if _, ok := g.typeMap[qualifiedTypeName(custom)]; ok {
+		return true
+	}
+	_, isStruct := g.structs[g.typeName(custom)]
+	_, isType := g.types[g.typeName(custom)]
+	return isStruct || isType
+}
+
+// dominantField returns the field which wins among fields with the same name.
+func dominantField(fields []jsonField) (jsonField, bool) {
+	sort.Slice(fields, func(i, j int) bool {
+		if len(fields[i].index) != len(fields[j].index) {
+			return len(fields[i].index) < len(fields[j].index)
+		}
+		if fields[i].tagged != fields[j].tagged {
+			return fields[i].tagged
+		}
+		return lessIndex(fields[i].index, fields[j].index)
+	})
+
+	if len(fields) > 1 && len(fields[0].index) == len(fields[1].index) && fields[0].tagged == fields[1].tagged {
+		return jsonField{}, false
+	}
+	return fields[0], true
+}
+
+func lessIndex(a, b []int) bool {
+	for k, x := range a {
+		if k >= len(b) {
+			return false
+		}
+		if x != b[k] {
+			return x < b[k]
+		}
+	}
+	return len(a) < len(b)
+}
@@ -1,11 +1,14 @@
 package avro
 
+import "encoding/json"
+
 // Protocol reflects limited to types avro protocol schema.
+// Types contains named types: records and enums.
 type Protocol struct {
-	Namespace string   `json:"namespace"`
-	Protocol  string   `json:"protocol"`
-	Doc       string   `json:"doc,omitempty"`
-	Types     []Record `json:"types"`
+	Namespace string        `json:"namespace"`
+	Protocol  string        `json:"protocol"`
+	Doc       string        `json:"doc,omitempty"`
+	Types     []interface{} `json:"types"`
 }
 
 // Record reflects avro record type schema.
@@ -17,11 +20,47 @@ type Record struct {
 	Fields    []Field `json:"fields"`
 }
 
+// Fixed reflects avro fixed type schema.
+type Fixed struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Namespace   string `json:"namespace,omitempty"`
+	Size        int    `json:"size"`
+	LogicalType string `json:"logicalType,omitempty"`
+	Precision   int    `json:"precision,omitempty"`
+	Scale       int    `json:"scale,omitempty"`
+}
+
+// Enum reflects avro enum type schema.
+type Enum struct {
+	Type      string   `json:"type"`
+	Name      string   `json:"name"`
+	Namespace string   `json:"namespace,omitempty"`
+	Doc       string   `json:"doc,omitempty"`
+	Symbols   []string `json:"symbols"`
+	Default   string   `json:"default,omitempty"`
+}
+
 // Field reflects field in avro record type.
 type Field struct {
-	Name string      `json:"name"`
-	Doc  string      `json:"doc,omitempty"`
-	Type interface{} `json:"type"`
+	Name    string          `json:"name"`
+	Doc     string          `json:"doc,omitempty"`
+	Type    interface{}     `json:"type"`
+	Default json.RawMessage `json:"default,omitempty"`
+	Order   string          `json:"order,omitempty"`
+	Aliases []string        `json:"aliases,omitempty"`
+	// GoType is a custom property with go type of the field, see Options.GoTypeProperty.
+	GoType string `json:"goType,omitempty"`
+}
+
+// LogicalType is a primitive type annotated with avro logical type,
+// e.g. {"type": "long", "logicalType": "timestamp-millis"}.
+type LogicalType struct {
+	Type        string `json:"type"`
+	LogicalType string `json:"logicalType"`
+	// Precision and Scale are attributes of decimal logical type.
+	Precision int `json:"precision,omitempty"`
+	Scale     int `json:"scale,omitempty"`
 }
 
 // Array is a array type of the field.
@@ -32,9 +71,18 @@ type Array struct {
 
 // Map is a map type of the field.
 type Map struct {
-	Type   string `json:"type"`
-	Values string `json:"values"`
+	Type   string      `json:"type"`
+	Values interface{} `json:"values"`
 }
 
-// Union is a union type of the field. used for nullable fields.
-type Union [2]interface{}
+// Union is a union type of the field. Generated unions are nullable types,
+// unions of any types could be loaded with the envelope schema.
+type Union []interface{}
+
+// valueType returns not null type of the union.
+func (u Union) valueType() interface{} {
+	if u[0] == "null" {
+		return u[1]
+	}
+	return u[0]
+}
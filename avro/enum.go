@@ -0,0 +1,102 @@
+package avro
+
+import (
+	"regexp"
+
+	"github.com/gojuno/genavro/astparser"
+)
+
+// avroNameRegexp matches valid avro names and enum symbols.
+var avroNameRegexp = regexp.MustCompile("^[A-Za-z_][A-Za-z0-9_]*$")
+
+const (
+	// EnumDirective in the type doc comment makes the type an enum even with a single constant,
+	// integer types are enums only with the directive.
+	EnumDirective = "genavro:enum"
+	// NoEnumDirective in the type doc comment keeps the type with constants its underlying primitive type,
+	// e.g. for `type Currency string` with DefaultCurrency constant which is not a closed set of values.
+	NoEnumDirective = "genavro:noenum"
+)
+
+// namedType is a named type of the sources with qualified go name typed constants refer it by,
+// e.g. github.com/acme/geo.Kind.
+type namedType struct {
+	astparser.TypeDef
+	goName string
+}
+
+// avroEnums builds avro enums from named string types which have a set of typed constants,
+// at least two of them or one with EnumDirective. Types with NoEnumDirective are never enums.
+// Symbols of string based enum are constant values as they are sent in json.
+// Integer types are enums only with EnumDirective as json carries their numbers while
+// symbols are constant names, such enums describe the values but do not match json encoding.
+// Constants are grouped by qualified go name of their type.
+// Types without constants are not enums and are skipped.
+func (g *generator) avroEnums(types []namedType, constants map[string][]astparser.ConstantDef) []Enum {
+	var enums []Enum
+	for _, t := range types {
+		simple, ok := t.Type.(astparser.TypeSimple)
+		if !ok || hasDirective(t.Comments, NoEnumDirective) {
+			continue
+		}
+		directive := hasDirective(t.Comments, EnumDirective)
+		if simple.Name != "string" && (!avroIsIntegerType(simple.Name) || !directive) {
+			continue
+		}
+
+		typed := constants[t.goName]
+		// a single constant is rather a default value than a closed set of values
+		if len(typed) == 0 || len(typed) == 1 && !directive {
+			continue
+		}
+
+		var symbols []string
+		seen := map[string]bool{}
+		for _, c := range typed {
+			symbol := c.Name
+			if simple.Name == "string" {
+				symbol = c.Value
+			}
+
+			if !avroNameRegexp.MatchString(symbol) {
+				g.errorf(c.Pos, t.Name, c.Name, "%q is not a valid avro enum symbol", symbol)
+				continue
+			}
+			if seen[symbol] {
+				continue
+			}
+			seen[symbol] = true
+			symbols = append(symbols, symbol)
+		}
+
+		if len(symbols) == 0 {
+			continue
+		}
+
+		e := Enum{
+			Type:    "enum",
+			Name:    t.Name,
+			Symbols: symbols,
+		}
+
+		doc, value, _ := commentDefault(t.Comments)
+		e.Doc, e.Default = structDoc(doc), value
+
+		if e.Default != "" && !seen[e.Default] {
+			g.errorf(t.Pos, t.Name, "", "enum default %q is not one of symbols %v", e.Default, symbols)
+			e.Default = ""
+		}
+
+		enums = append(enums, e)
+	}
+
+	return enums
+}
+
+func avroIsIntegerType(gotype string) bool {
+	switch gotype {
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "byte":
+		return true
+	}
+	return false
+}
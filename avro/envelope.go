@@ -0,0 +1,129 @@
+package avro
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/gojuno/genavro/astparser"
+	"github.com/pkg/errors"
+)
+
+const (
+	// EnvelopeDefault wraps payloads with built-in record of event_id, request_id, event_ts, type,
+	// minor_version and auth fields.
+	EnvelopeDefault = "default"
+	// EnvelopeNone emits bare payload records named after the events.
+	EnvelopeNone = "none"
+	// EnvelopeDirective in the struct doc comment makes the struct an envelope of all the events:
+	//
+	//	//genavro:envelope
+	//	type BaseEvent struct {...}
+	EnvelopeDirective = "genavro:envelope"
+	// DefaultPayloadField is a name of the envelope field with the payload.
+	DefaultPayloadField = "payload"
+)
+
+// LoadEnvelope loads avro record schema of the envelope from json file.
+// Named types the envelope refers must be defined inline. The envelope record itself is renamed
+// after the events and put to the namespace of the protocol, so it could not declare a namespace.
+func LoadEnvelope(path string) (*Record, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read envelope %s", path)
+	}
+
+	schema, err := parseSchema(bytes, "")
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse envelope %s", path)
+	}
+	envelope, ok := schema.(Record)
+	if !ok {
+		return nil, fmt.Errorf("envelope %s is %s, expected record", path, idlJSON(schema))
+	}
+
+	return &envelope, nil
+}
+
+// selectEnvelope finds the struct declared as envelope by name in options or by directive.
+// Struct is not used if envelope is set to none, default or loaded from config.
+func (g *generator) selectEnvelope(sources map[string]astparser.ParsedFile, files []string) error {
+	switch {
+	case g.opts.Envelope == EnvelopeNone || g.opts.Envelope == EnvelopeDefault || g.opts.EnvelopeSchema != nil:
+		return nil
+	case g.opts.Envelope != "":
+		s, ok := g.structs[g.opts.Envelope]
+		if !ok {
+			return fmt.Errorf("envelope struct %s is not found in the sources", g.opts.Envelope)
+		}
+		g.envelope = &s
+		return nil
+	}
+
+	for _, file := range files {
+		for _, s := range sources[file].Structs {
+			if !hasDirective(s.Comments, EnvelopeDirective) {
+				continue
+			}
+			if g.envelope != nil {
+				g.errorf(s.Pos, s.Name, "", "envelope is already declared by %s at %s", g.envelope.Name, g.envelope.Pos)
+				continue
+			}
+			s := s
+			g.envelope = &s
+		}
+	}
+	return nil
+}
+
+// avroEnvelope returns types wrapping the payload of the event, the envelope record is named after the event.
+// Dependencies of the struct envelope are collected with the ones of the payload.
+func (g *generator) avroEnvelope(s astparser.StructDef, payload Record, minorVersion string, collectDeps func(tpe interface{})) []interface{} {
+	payloadField := Field{Name: g.opts.PayloadField, Type: payload.Name}
+
+	switch {
+	case g.envelope != nil:
+		envelope := g.avroRecord(*g.envelope, collectDeps)
+		envelope.Name = s.Name
+		envelope.Fields = g.withPayloadField(*g.envelope, envelope.Fields, payloadField)
+		return []interface{}{envelope}
+	case g.opts.EnvelopeSchema != nil:
+		envelope := *g.opts.EnvelopeSchema
+		envelope.Name = s.Name
+		envelope.Fields = append(append([]Field{}, envelope.Fields...), payloadField)
+		return []interface{}{envelope}
+	default:
+		base := avroBaseV1Type(s.Name, minorVersion)
+		base.Fields = append(base.Fields, payloadField)
+		return []interface{}{avroAuthType, base}
+	}
+}
+
+// withPayloadField appends payload field to the fields of the envelope struct unless the name is taken.
+func (g *generator) withPayloadField(envelope astparser.StructDef, fields []Field, payload Field) []Field {
+	for _, f := range fields {
+		if f.Name == payload.Name {
+			g.errorf(envelope.Pos, envelope.Name, "", "envelope field %s conflicts with payload field, set other payload field name", f.Name)
+			return fields
+		}
+	}
+	return append(fields, payload)
+}
+
+// validEnvelope checks envelope options which do not depend on the sources.
+func validEnvelope(opts Options) error {
+	if !avroNameRegexp.MatchString(opts.PayloadField) {
+		return fmt.Errorf("%q is not a valid avro field name for payload", opts.PayloadField)
+	}
+	if opts.EnvelopeSchema == nil {
+		return nil
+	}
+	if namespace := namespaceOf(fullName(opts.EnvelopeSchema.Name, opts.EnvelopeSchema.Namespace)); namespace != "" {
+		return fmt.Errorf("envelope record %s declares namespace %s, envelopes are put to the namespace of the protocol", shortName(opts.EnvelopeSchema.Name), namespace)
+	}
+	for _, f := range opts.EnvelopeSchema.Fields {
+		if f.Name == opts.PayloadField {
+			return fmt.Errorf("envelope field %s conflicts with payload field, set other payload field name", f.Name)
+		}
+	}
+	return nil
+}
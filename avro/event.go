@@ -0,0 +1,129 @@
+package avro
+
+import (
+	"fmt"
+	"go/token"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gojuno/genavro/astparser"
+)
+
+// DefaultEventRegexp selects events by the name ending with version, e.g. MetricsV1.
+const DefaultEventRegexp = `.*V\d+$`
+
+// EventDirective in the struct doc comment selects the struct as event regardless of its name:
+//
+//	//genavro:event
+//	type RideFinished struct {...}
+const EventDirective = "genavro:event"
+
+// Event is a struct selected as top level event, Reason describes the rule which selected it.
+type Event struct {
+	Name   string
+	Pos    token.Position
+	Reason string
+}
+
+// Events returns structs which are generated to separate protocols with the options, ordered by name.
+func Events(sources map[string]astparser.ParsedFile, opts Options) ([]Event, error) {
+	selector, err := newEventSelector(opts.withDefaults())
+	if err != nil {
+		return nil, err
+	}
+
+	events := selector.selectEvents(sources, sortedFiles(sources))
+	result := make([]Event, 0, len(events))
+	for _, e := range events {
+		result = append(result, e)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// eventSelector selects events by directive, marker and name, regexp is nil if selection by name is disabled.
+type eventSelector struct {
+	regexp *regexp.Regexp
+	marker string
+}
+
+func newEventSelector(opts Options) (eventSelector, error) {
+	if opts.DisableEventRegexp {
+		return eventSelector{marker: opts.EventMarker}, nil
+	}
+	r, err := regexp.Compile(opts.EventRegexp)
+	if err != nil {
+		return eventSelector{}, fmt.Errorf("invalid event regexp %s: %v", opts.EventRegexp, err)
+	}
+	return eventSelector{regexp: r, marker: opts.EventMarker}, nil
+}
+
+// selectEvents returns events by name, the first declaration wins for the conflicting ones.
+func (e eventSelector) selectEvents(sources map[string]astparser.ParsedFile, files []string) map[string]Event {
+	events := map[string]Event{}
+	for _, file := range files {
+		for _, s := range sources[file].Structs {
+			if _, ok := events[s.Name]; ok {
+				continue
+			}
+			if reason := e.reason(s); reason != "" {
+				events[s.Name] = Event{Name: s.Name, Pos: s.Pos, Reason: reason}
+			}
+		}
+	}
+	return events
+}
+
+// reason returns the rule which selects the struct as event, it is empty for the other structs.
+func (e eventSelector) reason(s astparser.StructDef) string {
+	if hasDirective(s.Comments, EnvelopeDirective) {
+		return ""
+	}
+	if hasDirective(s.Comments, EventDirective) {
+		return "//" + EventDirective + " directive"
+	}
+	for _, f := range s.Fields {
+		if f.Embedded && e.isMarker(f.FieldType) {
+			return "embedded marker " + e.marker
+		}
+	}
+	for _, iface := range s.Implements {
+		if e.marker != "" && iface == e.marker {
+			return "marker interface " + e.marker
+		}
+	}
+	if e.regexp != nil && e.regexp.MatchString(s.Name) {
+		return "regexp " + e.regexp.String()
+	}
+	return ""
+}
+
+// isMarker checks if the embedded field type is the event marker.
+func (e eventSelector) isMarker(t astparser.Type) bool {
+	if p, ok := t.(astparser.TypePointer); ok {
+		t = p.InnerType
+	}
+	custom, ok := t.(astparser.TypeCustom)
+	return ok && e.marker != "" && qualifiedTypeName(custom) == e.marker
+}
+
+func hasDirective(comments []string, directive string) bool {
+	for _, c := range comments {
+		if c == directive {
+			return true
+		}
+	}
+	return false
+}
+
+// structDoc joins doc comments of the struct without genavro directives and blank lines separating them.
+func structDoc(comments []string) string {
+	doc := make([]string, 0, len(comments))
+	for _, c := range comments {
+		if c != "" && !strings.HasPrefix(c, "genavro:") {
+			doc = append(doc, c)
+		}
+	}
+	return strings.Join(doc, ", ")
+}
@@ -0,0 +1,32 @@
+package avro
+
+import (
+	"fmt"
+
+	"github.com/gojuno/genavro/astparser"
+)
+
+// avroBytesType maps byte slice to bytes as json encodes it to base64 string
+// and fixed size byte array to named fixed type of the same size, e.g. [16]byte to Fixed16.
+// Fixed types are added to dependencies to be declared once in the protocol.
+func (g *generator) avroBytesType(t astparser.TypeArray) (interface{}, error) {
+	switch t.Len {
+	case 0:
+		return "bytes", nil
+	case astparser.ArrayLenUnknown:
+		return nil, fmt.Errorf("length of byte array could not be evaluated, load sources with packages loader")
+	}
+
+	fixed := Fixed{
+		Type: "fixed",
+		Name: fmt.Sprintf("Fixed%d", t.Len),
+		Size: t.Len,
+	}
+	g.deps[fixed.Name] = dep{schema: fixed}
+	return fixed.Name, nil
+}
+
+// isByte checks if go type is byte, go/types names byte as uint8.
+func isByte(gotype string) bool {
+	return gotype == "byte" || gotype == "uint8"
+}
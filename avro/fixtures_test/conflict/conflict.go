@@ -0,0 +1,17 @@
+// Package conflict is a fixture package which declares type of the same name as the imported one.
+package conflict
+
+import "github.com/gojuno/genavro/avro/fixtures_test/typed/geo"
+
+// Point is a point on the screen, it conflicts with geo.Point.
+type Point struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+const minorVersionConflictV1 = "1"
+
+type ConflictV1 struct {
+	Local  Point     `json:"local"`
+	Remote geo.Point `json:"remote"`
+}
@@ -0,0 +1,45 @@
+package diagnostics
+
+type Status string
+
+const (
+	StatusInProgress Status = "in-progress"
+	StatusDone       Status = "done"
+)
+
+const minorVersionInvalidV1 = "1"
+
+type InvalidV1 struct {
+	Status Status `json:"status"`
+	Date   string `json:"date" avro:"logical=date"`
+	Int    int    `json:"int"`
+	Dash   string `json:"-,"`
+	Order  int    `json:"order" avro:"sort=asc"`
+	Other  int    `json:"other" avro:"name=int"`
+	Limit  int32  `json:"limit" avro:"default=3000000000"`
+	// avro:default=unknown
+	State Status         `json:"state"`
+	Hash  [hashSize]byte `json:"hash"`
+	Price []byte         `json:"price" avro:"logical=decimal"`
+	Small [2]byte        `json:"small" avro:"logical=decimal,precision=10"`
+	Count int            `json:"count" avro:"precision=2"`
+	Ref   int            `json:"ref" avro:"logical=uuid"`
+	Tree  Tree           `json:"tree"`
+}
+
+const hashSize = 32
+
+type Tree map[string]Tree
+
+// Loop and Ring could not be serialized as their avro fields are not nullable.
+type Loop struct {
+	Next string `json:"next" avro:"type=Loop"`
+}
+
+type Ring struct {
+	Link Link `json:"link"`
+}
+
+type Link struct {
+	Ring string `json:"ring" avro:"type=Ring"`
+}
@@ -0,0 +1,17 @@
+package diagnostics
+
+import "bytes"
+
+const minorVersionUnresolvedV1 = "1"
+
+type UnresolvedV1 struct {
+	Buffer  bytes.Buffer `json:"buffer"`
+	Complex complex64    `json:"complex"`
+	Pointer uintptr      `json:"pointer"`
+	Typo    string       `json:"typo" avro:"type=strng"`
+	Done    chan bool    `json:"done"`
+	Any     interface{}  `json:"any"`
+	Length  float64      `json:"length" avro:"type=Meters"`
+}
+
+type Meters float64
@@ -0,0 +1,16 @@
+package envelope
+
+//genavro:envelope
+type BaseEvent struct {
+	ID        string  `json:"id"`
+	CreatedAt int64   `json:"created_at"`
+	Source    *Source `json:"source,omitempty"`
+}
+
+type Source struct {
+	Service string `json:"service"`
+}
+
+type CreatedV1 struct {
+	Name string `json:"name"`
+}
@@ -0,0 +1,34 @@
+package events
+
+// Event is a marker of the events embedded in them.
+type Event struct{}
+
+// ConfigV2 is a helper struct which is not an event despite its name.
+type ConfigV2 struct {
+	Retries int `json:"retries"`
+}
+
+type OrderV1 struct {
+	Config ConfigV2 `json:"config"`
+}
+
+//genavro:event
+type RideFinished struct {
+	RideID string `json:"ride_id"`
+}
+
+type PaymentDone struct {
+	Event
+	Amount int `json:"amount"`
+}
+
+// Tracked is a marker interface of the events implementing it.
+type Tracked interface {
+	tracked()
+}
+
+type DriverAssigned struct {
+	DriverID string `json:"driver_id"`
+}
+
+func (*DriverAssigned) tracked() {}
@@ -0,0 +1,16 @@
+package fixtures_test
+
+const minorVersionTaggedV1 = "1"
+
+type TaggedV1 struct {
+	// TripID is renamed in avro only.
+	TripID  string            `json:"id" avro:"name=trip_id,alias=id,alias=ride_id"`
+	Count   uint64            `json:"count" avro:"type=long"`
+	Day     int32             `json:"day" avro:"logical=date,doc=days since epoch"`
+	Comment *string           `json:"comment,omitempty" avro:"default=null,order=ignore"`
+	Labels  map[string]string `json:"labels" avro:"default={\"a\":\"x\",\"b\":\"y\"},order=ignore"`
+	// Note has avro tag before json tag.
+	Note *string `avro:"doc=Trip note shown to the driver" json:"note,omitempty"`
+	// PreviousTripID overrides type of the pointer.
+	PreviousTripID *string `json:"previous_trip_id" avro:"name=trip_id2,type=string,logical=uuid,default=null"`
+}
@@ -0,0 +1,19 @@
+package fixtures_test
+
+type Device struct {
+	ID   [16]byte `json:"id"`
+	Spec []byte   `json:"spec"`
+}
+
+const minorVersionBytesV1 = "1"
+
+type BytesV1 struct {
+	Raw       []byte            `json:"raw"`
+	UUID      [16]byte          `json:"uuid"`
+	Hash      *[32]byte         `json:"hash,omitempty"`
+	Keys      [][0x10]byte      `json:"keys"`
+	Documents map[string][]byte `json:"documents"`
+	Device    Device            `json:"device"`
+	Flags     [4]int            `json:"flags"`
+	Version   byte              `json:"version"`
+}
@@ -0,0 +1,30 @@
+package fixtures_test
+
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+type Segment struct {
+	Points []Point `json:"points"`
+}
+
+type Zone struct {
+	Name string `json:"name"`
+}
+
+type Driver struct {
+	Name string `json:"name"`
+}
+
+const minorVersionCollectionsV1 = "1"
+
+type CollectionsV1 struct {
+	Counters  map[string][]int              `json:"counters"`
+	Segments  map[string]*Segment           `json:"segments"`
+	Zones     map[string][]map[string]Zone  `json:"zones"`
+	Drivers   []map[string]*Driver          `json:"drivers,omitempty"`
+	Nullable  map[string]*int64             `json:"nullable"`
+	Nested    map[string]map[string][]Point `json:"nested"`
+	Timestamp map[string]int64              `json:"timestamp" avro:"logical=timestamp-millis"`
+}
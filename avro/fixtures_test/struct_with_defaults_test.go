@@ -0,0 +1,14 @@
+package fixtures_test
+
+const minorVersionDefaultsV1 = "1"
+
+type DefaultsV1 struct {
+	// Retries is a number of retries.
+	// avro:default=3
+	Retries int        `json:"retries"`
+	Status  RideStatus `json:"status" avro:"default=started"`
+	Label   string     `json:"label,omitempty" avro:"default=none"`
+	Comment *string    `json:"comment,omitempty"`
+	Ratio   float64    `json:"ratio" avro:"default=0.5"`
+	Enabled bool       `json:"enabled" avro:"default=true"`
+}
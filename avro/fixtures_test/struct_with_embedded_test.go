@@ -0,0 +1,39 @@
+package fixtures_test
+
+type Base struct {
+	Kind string `json:"kind"`
+}
+
+type Common struct {
+	Base
+	ID     string `json:"id"`
+	Source string
+}
+
+type Meta struct {
+	Source  string
+	Version int `json:"version"`
+}
+
+type Extra struct {
+	Note string `json:"note"`
+}
+
+type Audit struct {
+	By string `json:"by"`
+}
+
+const minorVersionEmbeddedV1 = "1"
+
+type EmbeddedV1 struct {
+	// Common fields are promoted, id is shadowed by the top level field.
+	Common
+	// Meta source conflicts with Common source at the same depth, both are dropped.
+	Meta
+	// Extra fields are promoted as nullable.
+	*Extra
+	// Audit is tagged so it is a nested record.
+	Audit `json:"audit"`
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+}
@@ -0,0 +1,69 @@
+package fixtures_test
+
+// RideStatus is a status of the ride.
+// avro:default=created
+type RideStatus string
+
+const (
+	RideStatusCreated   RideStatus = "created"
+	RideStatusStarted   RideStatus = "started"
+	RideStatusCompleted            = RideStatus("completed")
+)
+
+// Priority is sent in json as a number and stays int.
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+)
+
+// Gear is an integer enum described by the names of its constants.
+//
+//genavro:enum
+type Gear int
+
+const (
+	GearPark Gear = iota
+	GearDrive
+)
+
+// Currency with the default constant only is not an enum.
+type Currency string
+
+const DefaultCurrency Currency = "USD"
+
+// Region is an open set of values.
+//
+//genavro:noenum
+type Region string
+
+const (
+	RegionEU Region = "eu"
+	RegionUS Region = "us"
+)
+
+// Kind is an enum of one symbol so far.
+//
+//genavro:enum
+type Kind string
+
+const KindTaxi Kind = "taxi"
+
+type Ride struct {
+	Status   RideStatus `json:"status"`
+	Priority Priority   `json:"priority"`
+}
+
+const minorVersionEnumV1 = "1"
+
+type EnumV1 struct {
+	Ride     Ride        `json:"ride"`
+	Status   *RideStatus `json:"status,omitempty"`
+	Previous []Priority  `json:"previous"`
+	Currency Currency    `json:"currency"`
+	Region   Region      `json:"region"`
+	Kind     Kind        `json:"kind"`
+	Gear     Gear        `json:"gear"`
+}
@@ -0,0 +1,12 @@
+package fixtures_test
+
+const minorVersionGroupedV1 = "1"
+
+// GroupedV1 declares several fields of the same type on one line.
+type GroupedV1 struct {
+	Lat, Lon float64
+	// From and To are ids of the places.
+	From, To   string
+	Start, End *int64
+	Stops      []int `json:"stops"`
+}
@@ -0,0 +1,22 @@
+package fixtures_test
+
+type hidden struct {
+	Promoted string `json:"promoted"`
+	secret   string
+}
+
+type unexportedID string
+
+const minorVersionIgnoredV1 = "1"
+
+type IgnoredV1 struct {
+	// hidden is unexported but its exported fields are promoted.
+	hidden
+	unexportedID
+	ID       string `json:"id"`
+	Password string `json:"-"`
+	Untagged string
+	internal string
+	cancel   func()
+	Callback func(string) `json:"-"`
+}
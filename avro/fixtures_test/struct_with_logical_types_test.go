@@ -0,0 +1,27 @@
+package fixtures_test
+
+import (
+	"time"
+
+	"junolab.net/lib_api/timeapi"
+)
+
+// Time is a local type which should not be confused with time.Time.
+type Time struct {
+	Hour int `json:"hour"`
+}
+
+const minorVersionLogicalV1 = "1"
+
+type LogicalV1 struct {
+	Time         time.Time     `json:"time"`
+	TimeOpt      *timeapi.Time `json:"time_opt,omitempty"`
+	Date         int32         `json:"date" avro:"logical=date"`
+	TimeOfDay    int           `json:"time_of_day" avro:"logical=time-millis"`
+	TimestampOpt *int64        `json:"timestamp_opt,omitempty" avro:"logical=timestamp-micros"`
+	Timestamps   []int64       `json:"timestamps" avro:"logical=timestamp-millis"`
+	Micros       timeapi.Time  `json:"micros" avro:"logical=timestamp-micros"`
+	Duration     time.Duration `json:"duration"`
+	Local        Time          `json:"local"`
+	Created      *time.Time    `json:"created,omitempty" avro:"logical=timestamp-millis"`
+}
@@ -0,0 +1,18 @@
+package fixtures_test
+
+import (
+	"junolab.net/lib_api/core"
+)
+
+// ID is a local type which should not be confused with core.ID.
+type ID struct {
+	Value int64 `json:"value"`
+}
+
+const minorVersionMappedV1 = "1"
+
+type MappedV1 struct {
+	CoreID  core.ID  `json:"core_id"`
+	LocalID ID       `json:"local_id"`
+	OptID   *core.ID `json:"opt_id,omitempty"`
+}
@@ -0,0 +1,24 @@
+package fixtures_test
+
+// UserID is an identifier of the user.
+type UserID string
+
+type Meters float64
+
+type Route []Point
+
+type Labels map[string]string
+
+type Distances map[string]Meters
+
+const minorVersionNamedV1 = "1"
+
+type NamedV1 struct {
+	UserID    UserID    `json:"user_id"`
+	Distance  Meters    `json:"distance"`
+	Route     Route     `json:"route"`
+	Labels    Labels    `json:"labels,omitempty"`
+	Friends   []UserID  `json:"friends"`
+	Distances Distances `json:"distances"`
+	Stopped   *Meters   `json:"stopped,omitempty"`
+}
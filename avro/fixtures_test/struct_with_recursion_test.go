@@ -0,0 +1,28 @@
+package fixtures_test
+
+// Node refers to itself.
+type Node struct {
+	Name     string `json:"name"`
+	Children []Node `json:"children"`
+	Parent   *Node  `json:"parent,omitempty"`
+}
+
+// Employee and Department refer to each other.
+type Employee struct {
+	Name       string      `json:"name"`
+	Department *Department `json:"department,omitempty"`
+}
+
+type Department struct {
+	Name  string     `json:"name"`
+	Head  *Employee  `json:"head,omitempty"`
+	Staff []Employee `json:"staff"`
+}
+
+const minorVersionRecursiveV1 = "1"
+
+type RecursiveV1 struct {
+	Root       Node       `json:"root"`
+	Staff      []Employee `json:"staff"`
+	Department Department `json:"department"`
+}
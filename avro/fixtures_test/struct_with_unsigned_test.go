@@ -0,0 +1,14 @@
+package fixtures_test
+
+type Counter uint64
+
+const minorVersionUnsignedV1 = "1"
+
+type UnsignedV1 struct {
+	Small   uint16            `json:"small"`
+	Medium  uint32            `json:"medium"`
+	Big     uint64            `json:"big"`
+	Size    uint              `json:"size,omitempty"`
+	Counter Counter           `json:"counter"`
+	Totals  map[string]uint64 `json:"totals"`
+}
@@ -0,0 +1,14 @@
+package fixtures_test
+
+import "github.com/pborman/uuid"
+
+const minorVersionPaymentV1 = "1"
+
+type PaymentV1 struct {
+	ID       uuid.UUID         `json:"id"`
+	ParentID *uuid.UUID        `json:"parent_id,omitempty"`
+	TraceID  string            `json:"trace_id" avro:"logical=uuid"`
+	Amount   []byte            `json:"amount" avro:"logical=decimal,precision=10,scale=2"`
+	Fee      [16]byte          `json:"fee" avro:"logical=decimal,precision=38,scale=4"`
+	Rates    map[string][]byte `json:"rates" avro:"logical=decimal,precision=6,scale=6"`
+}
@@ -0,0 +1,29 @@
+// Package geo is a fixture package with types referred from the other package.
+package geo
+
+import "time"
+
+// Point is a geo point.
+type Point struct {
+	Lat  float64 `json:"lat"`
+	Lon  float64 `json:"lon"`
+	Kind Kind    `json:"kind"`
+	Alt  Meters  `json:"alt"`
+}
+
+// Meters is a distance in meters.
+type Meters float64
+
+// Kind is a kind of the point.
+type Kind string
+
+const (
+	KindPickup  Kind = "pickup"
+	KindDropoff Kind = "dropoff"
+)
+
+// Track is a list of the points visited.
+type Track struct {
+	Points    []Point   `json:"points"`
+	StartedAt time.Time `json:"started_at"`
+}
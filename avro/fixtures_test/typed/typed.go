@@ -0,0 +1,24 @@
+// Package typed is a fixture package which refers types declared in other packages.
+package typed
+
+import (
+	"crypto/sha256"
+
+	"github.com/gojuno/genavro/avro/fixtures_test/typed/geo"
+	"junolab.net/lib_api/core"
+)
+
+// Location is an alias of the type from other package.
+type Location = geo.Point
+
+const minorVersionTypedV1 = "1"
+
+type TypedV1 struct {
+	ID       core.ID    `json:"id"`
+	Pickup   Location   `json:"pickup"`
+	Dropoff  *geo.Point `json:"dropoff,omitempty"`
+	Track    geo.Track  `json:"track"`
+	LastKind geo.Kind   `json:"last_kind"`
+	// Checksum length is evaluated by type checker.
+	Checksum [sha256.Size]byte `json:"checksum"`
+}
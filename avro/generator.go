@@ -2,12 +2,12 @@ package avro
 
 import (
 	"fmt"
+	"go/token"
 	"log"
-	"regexp"
 	"sort"
 	"strings"
 
-	"github.com/mkorolyov/astparser"
+	"github.com/gojuno/genavro/astparser"
 )
 
 var avroAuthType = Record{
@@ -15,46 +15,212 @@ var avroAuthType = Record{
 	Type: "record",
 	Fields: []Field{
 		{
-			Type: newUnion("string"),
-			Name: "session_id",
+			Type:    newUnion("string"),
+			Name:    "session_id",
+			Default: nullDefault,
 		},
 		{
-			Type: newUnion("string"),
-			Name: "user_id",
+			Type:    newUnion("string"),
+			Name:    "user_id",
+			Default: nullDefault,
 		},
 		{
-			Type: newUnion("string"),
-			Name: "app_id",
+			Type:    newUnion("string"),
+			Name:    "app_id",
+			Default: nullDefault,
 		},
 		{
-			Type: newUnion("string"),
-			Name: "app_version",
+			Type:    newUnion("string"),
+			Name:    "app_version",
+			Default: nullDefault,
 		},
 	},
 }
 
+// dep is a named avro type (record or enum) with names of types it depends on.
 type dep struct {
-	record Record
+	schema interface{}
 	deps   []string
+	// required are non-nullable fields referring records, see checkRequiredCycles.
+	required []requiredRef
 }
 
-// Generate converts structs from parsed files to avro protocol.
+// Options tunes avro generation.
+type Options struct {
+	// TimeLogicalType is an avro logical type for timeapi.Time fields without `avro:"logical=..."` tag.
+	// Defaults to timestamp-millis.
+	TimeLogicalType string
+	// TypeMap maps fully qualified go types to avro schemas, see LoadTypeMap.
+	// It is merged with built-in mappings and overrides them.
+	TypeMap map[string]interface{}
+	// LossyIntegers keeps mapping uint32 to int and uint64 to long which overflow for the big values.
+	// By default uint32 is widened to long and uint64 is mapped to Uint64Type.
+	LossyIntegers bool
+	// Uint64Type is an avro type for uint64 fields: Uint64Long (default), Uint64Decimal or Uint64String.
+	Uint64Type string
+	// GoTypeProperty records go type of the fields which refer named types resolved to their underlying types
+	// in goType field property, e.g. "goType": "UserID".
+	GoTypeProperty bool
+	// WarnUntagged reports exported fields without json tag,
+	// encoding/json names them after the go field which is rarely intended.
+	WarnUntagged bool
+	// LoadedPackages tells that the sources are loaded with astparser.LoadPackages which follows
+	// types of the imported packages, diagnostics of the unresolved types do not suggest it then.
+	LoadedPackages bool
+	// DisambiguateNamespaces names conflicting types declared in other packages with the namespace
+	// of their package, e.g. github_com.acme.geo.Location, instead of reporting the conflict.
+	DisambiguateNamespaces bool
+	// EventRegexp selects structs generated to separate protocols by name, defaults to DefaultEventRegexp.
+	// Structs with EventDirective or EventMarker are events regardless of their names.
+	EventRegexp string
+	// DisableEventRegexp turns off selection of the events by name, they are selected
+	// by EventDirective and EventMarker only.
+	DisableEventRegexp bool
+	// EventMarker is a fully qualified go type which makes the struct embedding it an event,
+	// e.g. junolab.net/lib_api/events.Event. The marker field itself is not generated.
+	// Marker interface makes the struct implementing it an event, implementations are found
+	// by LoadPackages with the marker listed in astparser.Config.Interfaces.
+	EventMarker string
+	// Envelope selects the record wrapping payloads of the events: EnvelopeDefault, EnvelopeNone
+	// or a name of the struct in the sources. By default the struct with EnvelopeDirective is used if any.
+	Envelope string
+	// EnvelopeSchema is an envelope record loaded from config, see LoadEnvelope. It overrides Envelope.
+	EnvelopeSchema *Record
+	// PayloadField is a name of the envelope field with the payload, defaults to DefaultPayloadField.
+	PayloadField string
+}
+
+func (o Options) withDefaults() Options {
+	if o.TimeLogicalType == "" {
+		o.TimeLogicalType = logicalTimestampMillis
+	}
+	if o.Uint64Type == "" {
+		o.Uint64Type = Uint64Long
+	}
+	if o.EventRegexp == "" {
+		o.EventRegexp = DefaultEventRegexp
+	}
+	if o.PayloadField == "" {
+		o.PayloadField = DefaultPayloadField
+	}
+	return o
+}
+
+type generator struct {
+	opts     Options
+	typeMap  map[string]interface{}
+	selector eventSelector
+	// events contains structs generated to separate protocols by name.
+	events map[string]Event
+	// envelope is a struct wrapping payloads of the events, see selectEnvelope.
+	envelope *astparser.StructDef
+	// structs and types contain all parsed structs and named types by avro name.
+	structs map[string]astparser.StructDef
+	types   map[string]astparser.TypeDef
+	enums   map[string]Enum
+	// deps contains named dependency types, fixed types are added while fields are generated.
+	deps map[string]dep
+	// resolving contains named types being resolved to their underlying types to detect recursion.
+	resolving map[string]bool
+	// names contains avro names of the disambiguated types by qualified go name, see detectConflicts.
+	names       map[string]string
+	diagnostics []Diagnostic
+	reported    map[Diagnostic]bool
+}
+
+// Generate converts structs from parsed files to avro protocol with default options.
 // Every struct ending with `V\d` will be parsed as Separate top level event and will be generated to separate protocol.
 // e.g. MetricsV1 will be generated as separate protocol and Metrics will be not.
+//
+// Generate fails with log.Fatalf on the first generation error,
+// use GenerateWithOptions to get all the problems as diagnostics.
 func Generate(sources map[string]astparser.ParsedFile, namespace string) map[string]Protocol {
+	protocols, diagnostics, err := GenerateWithOptions(sources, namespace, Options{})
+	if err != nil {
+		log.Fatalf("failed to generate avro protocols: %v", err)
+	}
+	for _, d := range diagnostics {
+		if d.Severity == SeverityError {
+			log.Fatal(d)
+		}
+	}
+	return protocols
+}
 
-	r := regexp.MustCompile(".*V\\d+$")
-	deps := map[string]dep{}
-	versions := map[string]string{}
+// GenerateWithOptions converts structs from parsed files to avro protocol like Generate does
+// but allows to tune the generation with options.
+// Problems found in the sources are returned as diagnostics, fields with error diagnostics
+// are skipped in the generated protocols. Error is returned only for invalid options.
+func GenerateWithOptions(sources map[string]astparser.ParsedFile, namespace string, opts Options) (map[string]Protocol, []Diagnostic, error) {
+	opts = opts.withDefaults()
+	if !timeLogicalTypes[opts.TimeLogicalType] {
+		return nil, nil, fmt.Errorf("unsupported time logical type %s, expected one of date, time-millis, time-micros, timestamp-millis, timestamp-micros", opts.TimeLogicalType)
+	}
+	if err := validUint64Type(opts.Uint64Type); err != nil {
+		return nil, nil, err
+	}
+	if err := validEnvelope(opts); err != nil {
+		return nil, nil, err
+	}
+	if opts.DisambiguateNamespaces && namespace == "" {
+		return nil, nil, fmt.Errorf("disambiguated namespaces require namespace of the protocol, types of the null namespace could not be referred from other namespaces")
+	}
+	selector, err := newEventSelector(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	g := &generator{
+		opts:      opts,
+		typeMap:   defaultTypeMap(opts),
+		selector:  selector,
+		structs:   map[string]astparser.StructDef{},
+		types:     map[string]astparser.TypeDef{},
+		enums:     map[string]Enum{},
+		deps:      map[string]dep{},
+		resolving: map[string]bool{},
+		names:     map[string]string{},
+		reported:  map[Diagnostic]bool{},
+	}
+	if err := validTypeMap(opts.TypeMap); err != nil {
+		return nil, nil, err
+	}
+	for name, schema := range opts.TypeMap {
+		g.typeMap[name] = schema
+	}
 
-	for _, parsedFile := range sources {
-		// build dependencies map
+	deps := g.deps
+	versions := map[string]string{}
+	var types []namedType
+	constants := map[string][]astparser.ConstantDef{}
+
+	files := sortedFiles(sources)
+	g.events = selector.selectEvents(sources, files)
+	g.detectConflicts(sources, files)
+	for _, file := range files {
+		parsedFile := g.withDeclaredNames(sources[file])
 		for _, s := range parsedFile.Structs {
-			// skip events
-			if r.Match([]byte(s.Name)) {
+			if _, ok := g.structs[s.Name]; !ok {
+				g.structs[s.Name] = s
+			}
+		}
+		for i, t := range parsedFile.Types {
+			if _, ok := g.types[t.Name]; !ok {
+				g.types[t.Name] = t
+				goName := qualifiedTypeName(astparser.TypeCustom{Name: sources[file].Types[i].Name, Package: parsedFile.Package})
+				types = append(types, namedType{TypeDef: t, goName: goName})
+			}
+		}
+
+		for _, c := range parsedFile.Constants {
+			if c.Type == "" {
 				continue
 			}
-			deps[s.Name] = parseDep(s)
+			pkg := c.TypePackage
+			if pkg == "" {
+				pkg = parsedFile.Package
+			}
+			goName := qualifiedTypeName(astparser.TypeCustom{Name: c.Type, Package: pkg})
+			constants[goName] = append(constants[goName], c)
 		}
 
 		// build minor version map
@@ -68,80 +234,113 @@ func Generate(sources map[string]astparser.ParsedFile, namespace string) map[str
 		}
 	}
 
+	if err := g.selectEnvelope(sources, files); err != nil {
+		return nil, nil, err
+	}
+
+	// enums could be declared in one file and their values in another,
+	// they are built first to check defaults of the fields
+	for _, e := range g.avroEnums(types, constants) {
+		g.enums[e.Name] = e
+		deps[e.Name] = dep{schema: e}
+	}
+
+	// build dependencies map in order of names, parsing adds fixed types and diagnostics
+	names := make([]string, 0, len(g.structs))
+	for name := range g.structs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		// skip events
+		if g.isEvent(name) {
+			continue
+		}
+		deps[name] = g.parseDep(g.structs[name])
+	}
+	g.checkRequiredCycles(deps)
+
+	// fixed types declared by events are added to the graph on demand
+	graph := newDepGraph(deps, func(name string) []string { return deps[name].deps })
+
 	result := map[string]Protocol{}
-	for _, parsedFile := range sources {
-		for _, s := range parsedFile.Structs {
-			// pass only events ends on
-			if !r.Match([]byte(s.Name)) {
+	for _, file := range files {
+		for _, s := range sources[file].Structs {
+			// pass only events ends on, conflicting events are reported by detectConflicts
+			if _, ok := result[s.Name]; ok || !g.isEvent(s.Name) {
 				continue
 			}
-			p := avroProtocol(s, deps, namespace, versions[s.Name])
+			p := g.avroProtocol(s, graph, namespace, versions[s.Name])
 			result[s.Name] = p
 		}
-
 	}
 
-	return result
+	sortDiagnostics(g.diagnostics)
+	return result, g.diagnostics, nil
 }
 
-func avroProtocol(s astparser.StructDef, deps map[string]dep, namespace, minorVersion string) Protocol {
-	base := avroBaseV1Type(s.Name, minorVersion)
-	base.Fields = append(base.Fields, Field{
-		Name: "payload",
-		Type: payloadName(s.Name),
-	})
+// isEvent checks if the struct is a top level event generated to separate protocol.
+func (g *generator) isEvent(name string) bool {
+	_, ok := g.events[name]
+	return ok
+}
+
+// errorf reports error diagnostic for the struct field, field could be empty.
+func (g *generator) errorf(pos token.Position, structName, field, format string, args ...interface{}) {
+	g.report(SeverityError, pos, structName, field, format, args...)
+}
 
+func (g *generator) report(severity Severity, pos token.Position, structName, field, format string, args ...interface{}) {
+	d := Diagnostic{
+		Pos:      pos,
+		Struct:   structName,
+		Field:    field,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+	}
+	// fields of embedded structs are checked for every struct they are promoted to
+	if g.reported[d] {
+		return
+	}
+	g.reported[d] = true
+	g.diagnostics = append(g.diagnostics, d)
+}
+
+func (g *generator) avroProtocol(s astparser.StructDef, graph *depGraph, namespace, minorVersion string) Protocol {
 	protocol := Protocol{
 		Namespace: namespace,
 		Protocol:  s.Name,
-		Types:     []Record{avroAuthType, base},
 	}
 
-	notUniqueDeps := map[int]dep{}
-	depIndex := 0
-	rs := avroRecord(s, func(tpe interface{}) {
-		if d := avroDep(deps, tpe); d != nil {
-			findDeps(*d, deps, notUniqueDeps, &depIndex)
-
-			notUniqueDeps[depIndex] = *d
-			depIndex++
-		}
-	})
-	rs.Name = payloadName(rs.Name)
-
-	uniqueDepsIndex := map[string]int{}
-	for i, d := range notUniqueDeps {
-		currentI, ok := uniqueDepsIndex[d.record.Name]
-		if currentI > i || !ok {
-			uniqueDepsIndex[d.record.Name] = i
-		}
+	var roots []string
+	collectDeps := func(tpe interface{}) {
+		roots = append(roots, avroDepNames(tpe)...)
 	}
-
-	uniqueDeps := make([]Record, 0, len(uniqueDepsIndex))
-	for _, index := range uniqueDepsIndex {
-		uniqueDeps = append(uniqueDeps, notUniqueDeps[index].record)
+	rs := g.avroRecord(s, collectDeps)
+	if g.opts.Envelope == EnvelopeNone && g.opts.EnvelopeSchema == nil {
+		protocol.Types = protocolNames(append(graph.order(roots), rs), namespace)
+		return protocol
 	}
 
-	sort.Slice(uniqueDeps, func(i, j int) bool {
-		return uniqueDepsIndex[uniqueDeps[i].Name] < uniqueDepsIndex[uniqueDeps[j].Name]
-	})
-
-	protocol.Types = append(append(uniqueDeps, rs), protocol.Types...)
+	rs.Name = payloadName(rs.Name)
+	envelope := g.avroEnvelope(s, rs, minorVersion, collectDeps)
+	protocol.Types = protocolNames(append(append(graph.order(roots), rs), envelope...), namespace)
 
 	return protocol
 }
 
-func findDeps(d dep, deps map[string]dep, notUniqueDeps map[int]dep, depIndex *int) {
-	for _, depOfDep := range d.deps {
-		if dd, ok := deps[depOfDep]; ok {
-			if len(dd.deps) > 0 {
-				findDeps(dd, deps, notUniqueDeps, depIndex)
-			}
-
-			notUniqueDeps[*depIndex] = dd
-			*depIndex++
-		}
+// protocolNames names the generated types as avro resolves them. Short names of the generator
+// are names of the protocol namespace, they are qualified when referred from types of other namespaces,
+// e.g. disambiguated ones.
+func protocolNames(types []interface{}, namespace string) []interface{} {
+	named := make([]interface{}, 0, len(types))
+	for _, tpe := range types {
+		qualified := renameTypes(tpe, namespace, func(name, _ string) (string, string) {
+			return fullName(name, namespace), namespace
+		})
+		named = append(named, relativeNames(qualified, namespace))
 	}
+	return named
 }
 
 func avroBaseV1Type(name, minorVersion string) Record {
@@ -172,115 +371,256 @@ func avroBaseV1Type(name, minorVersion string) Record {
 				Doc:  fmt.Sprintf("minorVersion=%s", minorVersion),
 			},
 			{
-				Name: "auth",
-				Type: newUnion("Auth"),
+				Name:    "auth",
+				Type:    newUnion("Auth"),
+				Default: nullDefault,
 			},
 		},
 	}
 }
 
-func avroDepName(tpe interface{}) string {
+// avroSchemaName returns name of the named avro type.
+func avroSchemaName(schema interface{}) string {
+	switch v := schema.(type) {
+	case Record:
+		return v.Name
+	case Enum:
+		return v.Name
+	case Fixed:
+		return v.Name
+	default:
+		return ""
+	}
+}
+
+// avroDepNames returns names of the named types avro type refers to at any depth.
+func avroDepNames(tpe interface{}) []string {
 	switch t := tpe.(type) {
 	case string:
-		return t
-	case Map:
-		return t.Values
+		if avroIsPrimitiveType(t) {
+			return nil
+		}
+		return []string{t}
 	case Array:
-		return avroDepName(t.Items)
+		return avroDepNames(t.Items)
+	case Map:
+		return avroDepNames(t.Values)
 	case Union:
-		return avroDepName(t[1])
+		var names []string
+		for _, u := range t {
+			names = append(names, avroDepNames(u)...)
+		}
+		return names
 	default:
-		return ""
+		// logical types and schemas from the type map
+		return nil
 	}
 }
 
-func avroDep(deps map[string]dep, tpe interface{}) *dep {
-	if dep, ok := deps[avroDepName(tpe)]; ok {
-		return &dep
+func (g *generator) avroRecord(s astparser.StructDef, collectDeps func(tpe interface{})) Record {
+	fields, _ := g.avroFields(s)
+	for _, f := range fields {
+		collectDeps(f.Type)
 	}
-	return nil
-}
 
-func avroRecord(s astparser.StructDef, collectDeps func(tpe interface{})) Record {
-	fields := make([]Field, 0, len(s.Fields))
-	for _, f := range s.Fields {
-		field := Field{
-			Name: f.JsonName,
-			Doc:  strings.Join(f.Comments, ", ")}
+	return Record{
+		Name:   s.Name,
+		Type:   "record",
+		Doc:    structDoc(s.Comments),
+		Fields: fields,
+	}
+}
 
-		field.Type = avroType(f.FieldType)
-		if f.Omitempty {
-			field.Type = newUnion(field.Type)
+func (g *generator) parseDep(s astparser.StructDef) dep {
+	var deps []string
+	var required []requiredRef
+	fields, sources := g.avroFields(s)
+	for i, f := range fields {
+		deps = append(deps, avroDepNames(f.Type)...)
+		if name, ok := f.Type.(string); ok && !avroIsPrimitiveType(name) {
+			required = append(required, requiredRef{name: name, field: sources[i].FieldName, pos: sources[i].Pos})
 		}
-
-		fields = append(fields, field)
-		collectDeps(field.Type)
 	}
 
-	return Record{
+	return dep{schema: Record{
 		Name:   s.Name,
 		Type:   "record",
-		Doc:    strings.Join(s.Comments, ", "),
+		Doc:    structDoc(s.Comments),
 		Fields: fields,
-	}
+	}, deps: deps, required: required}
 }
 
-func parseDep(s astparser.StructDef) dep {
-	var deps []string
+// avroFields builds avro fields of the struct with the struct fields they are built from,
+// fields which could not be generated are skipped.
+func (g *generator) avroFields(s astparser.StructDef) ([]Field, []jsonField) {
 	fields := make([]Field, 0, len(s.Fields))
-	for _, f := range s.Fields {
-		field := Field{
-			Name: f.JsonName,
-			Doc:  strings.Join(f.Comments, ", ")}
+	var sources []jsonField
+	names := map[string]bool{}
+	for _, f := range g.jsonFields(s) {
+		field, ok := g.avroField(s, f)
+		if !ok {
+			continue
+		}
+		// json names are unique, avro tag could rename a field to the existing one
+		if names[field.Name] {
+			g.errorf(f.Pos, s.Name, f.FieldName, "duplicate avro field name %s", field.Name)
+			continue
+		}
+		names[field.Name] = true
 
-		field.Type = avroType(f.FieldType)
-		if f.Omitempty {
+		fields = append(fields, field)
+		sources = append(sources, f)
+	}
+	return fields, sources
+}
+
+// avroField builds avro field from the struct field, avro tag overrides json name and inferred type.
+// It reports error diagnostic and returns false if the field could not be generated.
+func (g *generator) avroField(s astparser.StructDef, f jsonField) (Field, bool) {
+	tag, err := parseFieldTag(f.Tag)
+	if err != nil {
+		g.errorf(f.Pos, s.Name, f.FieldName, "%v", err)
+		return Field{}, false
+	}
+
+	doc, value, hasDefault := commentDefault(f.Comments)
+	defaultValue := tag.Default
+	if len(defaultValue) == 0 && hasDefault {
+		defaultValue = defaultLiteral(value)
+	}
+
+	field := Field{
+		Name:    f.name,
+		Doc:     strings.Join(doc, ", "),
+		Order:   tag.Order,
+		Aliases: tag.Aliases,
+	}
+	if tag.Name != "" {
+		field.Name = tag.Name
+	}
+	if tag.Doc != "" {
+		field.Doc = tag.Doc
+	}
+
+	if !avroNameRegexp.MatchString(field.Name) {
+		g.errorf(f.Pos, s.Name, f.FieldName, "%q is not a valid avro field name", field.Name)
+		return Field{}, false
+	}
+
+	if tag.Type != "" {
+		if _, ok := g.types[tag.Type]; ok && !g.isDeclared(tag.Type) {
+			g.errorf(f.Pos, s.Name, f.FieldName, "type %s is resolved to its underlying type and has no avro name, set the underlying avro type", tag.Type)
+			return Field{}, false
+		}
+		if !avroIsPrimitiveType(tag.Type) && !g.isDeclared(tag.Type) {
+			g.errorf(f.Pos, s.Name, f.FieldName, "unknown avro type %s, expected primitive type or name of the declared record or enum", tag.Type)
+			return Field{}, false
+		}
+		field.Type = tag.Type
+		// the override replaces the type pointer refers to, the field stays nullable
+		if _, ok := f.FieldType.(astparser.TypePointer); ok {
 			field.Type = newUnion(field.Type)
 		}
+	} else {
+		t, err := g.avroFieldType(f.FieldType, tag.Logical)
+		if err != nil {
+			g.errorf(f.Pos, s.Name, f.FieldName, "%v", err)
+			return Field{}, false
+		}
+		field.Type = t
+		g.lossyIntegers(s, f)
+	}
 
-		if !avroIsSimpleType(field.Type) {
-			deps = append(deps, avroInnerType(field.Type))
+	switch {
+	case tag.Logical == logicalDecimal || g.isDecimal(field.Type) && (tag.Precision > 0 || !hasPrecision(field.Type)):
+		t, err := g.withDecimal(field.Type, tag)
+		if err != nil {
+			g.errorf(f.Pos, s.Name, f.FieldName, "%v", err)
+			return Field{}, false
+		}
+		field.Type = t
+	case tag.Logical != "":
+		t, err := withLogicalType(field.Type, tag.Logical)
+		if err != nil {
+			g.errorf(f.Pos, s.Name, f.FieldName, "%v", err)
+			return Field{}, false
 		}
+		field.Type = t
+	}
+	if (tag.Precision > 0 || tag.Scale > 0) && !g.isDecimal(field.Type) {
+		g.errorf(f.Pos, s.Name, f.FieldName, "precision and scale could be set for decimal fields only")
+		return Field{}, false
+	}
+	if f.Omitempty || f.nullable {
+		field.Type = newUnion(field.Type)
+	}
 
-		fields = append(fields, field)
+	if g.opts.GoTypeProperty && g.refersNamedType(f.FieldType) {
+		field.GoType = goTypeString(f.FieldType)
 	}
 
-	return dep{record: Record{
-		Name:   s.Name,
-		Type:   "record",
-		Doc:    strings.Join(s.Comments, ", "),
-		Fields: fields,
-	}, deps: deps}
+	field = withDefault(field, defaultValue)
+	if len(field.Default) > 0 {
+		if err := g.checkDefault(field.Type, field.Default); err != nil {
+			g.errorf(f.Pos, s.Name, f.FieldName, "%v", err)
+			return Field{}, false
+		}
+	}
+
+	return field, true
 }
 
-func avroType(t astparser.Type) interface{} {
+func (g *generator) avroType(t astparser.Type) (interface{}, error) {
 	switch v := t.(type) {
 	case astparser.TypeSimple:
+		if unsigned, ok := g.avroUnsignedType(v.Name); ok {
+			return unsigned, nil
+		}
 		return avroSimpleType(v.Name)
 	case astparser.TypePointer:
-		return newUnion(avroType(v.InnerType))
+		inner, err := g.avroType(v.InnerType)
+		if err != nil {
+			return nil, err
+		}
+		return newUnion(inner), nil
 
 	case astparser.TypeArray:
-		return Array{Type: "array", Items: avroType(v.InnerType)}
+		if inner, ok := v.InnerType.(astparser.TypeSimple); ok && isByte(inner.Name) {
+			return g.avroBytesType(v)
+		}
+		items, err := g.avroType(v.InnerType)
+		if err != nil {
+			return nil, err
+		}
+		return Array{Type: "array", Items: items}, nil
 
 	case astparser.TypeMap:
-		return Map{Type: "map", Values: fmt.Sprint(avroType(v.ValueType))}
+		values, err := g.avroType(v.ValueType)
+		if err != nil {
+			return nil, err
+		}
+		return Map{Type: "map", Values: values}, nil
 
 	case astparser.TypeCustom:
-		switch v.Name {
-		// core.ID
-		case "ID":
-			return "string"
-		// timeapi.Time, //timeapi.Duration
-		case "Time", "Duration":
-			return "long"
-		default:
-			return v.Name
+		if mapped, ok := g.typeMap[qualifiedTypeName(v)]; ok {
+			return mapped, nil
+		}
+		if g.isNamedType(v) {
+			return g.avroNamedType(g.types[g.typeName(v)])
+		}
+		if !g.isDeclared(g.typeName(v)) {
+			if g.opts.LoadedPackages {
+				return nil, fmt.Errorf("type %s is not declared in the loaded packages, map it with -type-map", qualifiedTypeName(v))
+			}
+			return nil, fmt.Errorf("type %s is not declared in the parsed sources, load it with -load=packages or map it with -type-map", qualifiedTypeName(v))
 		}
+		return g.typeName(v), nil
+
+	case astparser.TypeUnsupported:
+		return nil, fmt.Errorf("unsupported go type %s", v.Name)
 
 	default:
-		log.Fatalf("unexpected go type %+[1]v: %[1]T", t)
-		return nil
+		return nil, fmt.Errorf("unexpected go type %+[1]v: %[1]T", t)
 	}
 }
 
@@ -288,67 +628,38 @@ func newUnion(t interface{}) Union {
 	switch v := t.(type) {
 	case Union:
 		return v
-	case Array:
-		return Union{"null", t}
-	case Map:
-		return Union{"null", t}
 	default:
-		return Union{"null", fmt.Sprint(t)}
+		return Union{"null", t}
 	}
 }
 
-func avroSimpleType(gotype string) string {
+func avroSimpleType(gotype string) (string, error) {
 	switch gotype {
-	case "int", "int8", "int16", "int32", "uint", "uint8", "uint16", "uint32":
-		return "int"
-		// TODO add support timeapi.Time -> timestamp-millis transition
-	case "int64", "uint64", "uintptr":
-		return "long"
+	case "int", "int8", "int16", "int32", "rune":
+		return "int", nil
+	case "int64":
+		return "long", nil
 	case "float32":
-		return "float"
+		return "float", nil
 	case "float64":
-		return "double"
+		return "double", nil
 	case "bool":
-		return "boolean"
-	case "[]byte":
-		return "bytes"
+		return "boolean", nil
 	case "string":
-		return "string"
+		return "string", nil
 	default:
-		log.Fatalf("unsupported go type %s", gotype)
-		return ""
+		return "", fmt.Errorf("unsupported go type %s", gotype)
 	}
 }
 
-func avroIsSimpleType(avroType interface{}) bool {
-	switch v := avroType.(type) {
-	case string:
-		switch v {
-		case "int", "long", "float", "double", "boolean", "bytes", "string":
-			return true
-		}
-	case Union:
-		return avroIsSimpleType(v[1])
+func avroIsPrimitiveType(avroType string) bool {
+	switch avroType {
+	case "null", "int", "long", "float", "double", "boolean", "bytes", "string":
+		return true
 	}
-
 	return false
 }
 
 func payloadName(name string) string {
 	return fmt.Sprintf("Payload%s", name)
 }
-
-func avroInnerType(t interface{}) string {
-	switch v := t.(type) {
-	case Array:
-		return avroInnerType(v.Items)
-	case Map:
-		return v.Values
-	case Union:
-		return avroInnerType(v[1])
-	case string:
-		return v
-	default:
-		return fmt.Sprint(t)
-	}
-}
@@ -0,0 +1,78 @@
+package avro
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gojuno/genavro/astparser"
+)
+
+// BenchmarkGenerate generates synthetic sources of growing size,
+// time per struct should stay the same as generation is linear in the number of structs.
+func BenchmarkGenerate(b *testing.B) {
+	for _, n := range []int{1250, 2500, 5000} {
+		sources := syntheticSources(n)
+		b.Run(fmt.Sprintf("structs=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, _, err := GenerateWithOptions(sources, "junolab.net", Options{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// syntheticSources returns n structs in blocks of diamond-shaped dependencies:
+// every struct refers two next structs of the block, so the number of paths grows exponentially
+// with the block size. Every block has an event which also refers struct shared by all events.
+func syntheticSources(n int) map[string]astparser.ParsedFile {
+	const blockSize = 50
+
+	common := astparser.StructDef{
+		Name: "Common",
+		Fields: []astparser.FieldDef{
+			{FieldName: "ID", JsonName: "id", FieldType: astparser.TypeSimple{Name: "string"}},
+		},
+	}
+	structs := []astparser.StructDef{common}
+	var constants []astparser.ConstantDef
+
+	for block := 0; block*blockSize < n; block++ {
+		name := func(i int) string {
+			return fmt.Sprintf("Dep%d_%d", block, i)
+		}
+
+		for i := 0; i < blockSize && block*blockSize+i < n; i++ {
+			s := astparser.StructDef{
+				Name: name(i),
+				Fields: []astparser.FieldDef{
+					{FieldName: "Value", JsonName: "value", FieldType: astparser.TypeSimple{Name: "int64"}},
+					{FieldName: "Common", JsonName: "common", FieldType: astparser.TypeCustom{Name: common.Name}},
+				},
+			}
+			for next := i + 1; next <= i+2 && next < blockSize && block*blockSize+next < n; next++ {
+				s.Fields = append(s.Fields, astparser.FieldDef{
+					FieldName: fmt.Sprintf("Next%d", next),
+					JsonName:  fmt.Sprintf("next_%d", next),
+					FieldType: astparser.TypeArray{InnerType: astparser.TypeCustom{Name: name(next)}},
+				})
+			}
+			structs = append(structs, s)
+		}
+
+		event := fmt.Sprintf("Event%dV1", block)
+		structs = append(structs, astparser.StructDef{
+			Name: event,
+			Fields: []astparser.FieldDef{
+				{FieldName: "Head", JsonName: "head", FieldType: astparser.TypeCustom{Name: name(0)}},
+				{FieldName: "Common", JsonName: "common", FieldType: astparser.TypeCustom{Name: common.Name}},
+			},
+		})
+		constants = append(constants, astparser.ConstantDef{Name: "minorVersion" + event, Value: "1"})
+	}
+
+	return map[string]astparser.ParsedFile{
+		"synthetic.go": {Structs: structs, Constants: constants},
+	}
+}
@@ -6,8 +6,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
 
-	"github.com/mkorolyov/astparser"
+	"github.com/gojuno/genavro/astparser"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -30,3 +33,680 @@ func TestGenerate(t *testing.T) {
 		assert.Equal(t, string(want), string(got))
 	}
 }
+
+func TestGenerate_Packages(t *testing.T) {
+	cfg := astparser.Config{InputDir: "fixtures_test/typed"}
+	sources, err := astparser.LoadPackages(cfg)
+	require.NoError(t, err)
+
+	protocols, diagnostics, err := GenerateWithOptions(sources, "junolab.net", Options{})
+	require.NoError(t, err)
+	require.Empty(t, diagnostics)
+	require.Len(t, protocols, 1)
+
+	got, err := json.MarshalIndent(protocols["TypedV1"], "", "    ")
+	require.NoError(t, err)
+	want, err := ioutil.ReadFile("fixtures_test/typed/TypedV1.avpr")
+	require.NoError(t, err)
+	assert.Equal(t, string(want), string(got))
+}
+
+func TestGenerateWithOptions_Deterministic(t *testing.T) {
+	var sources []map[string]astparser.ParsedFile
+	for _, dir := range []string{"fixtures_test", "fixtures_test/diagnostics"} {
+		parsed, err := astparser.Load(astparser.Config{InputDir: dir, IncludeRegexp: "test.go"})
+		require.NoError(t, err)
+		sources = append(sources, parsed)
+	}
+	parsed, err := astparser.LoadPackages(astparser.Config{InputDir: "fixtures_test/conflict"})
+	require.NoError(t, err)
+	sources = append(sources, parsed)
+
+	for _, parsed := range sources {
+		want := generateAll(t, parsed)
+		for i := 0; i < 50; i++ {
+			// fresh map is iterated in other order
+			copied := make(map[string]astparser.ParsedFile, len(parsed))
+			for name, file := range parsed {
+				copied[name] = file
+			}
+			require.Equal(t, want, generateAll(t, copied))
+		}
+	}
+}
+
+// generateAll returns generated protocols and diagnostics as text.
+func generateAll(t *testing.T, sources map[string]astparser.ParsedFile) string {
+	protocols, diagnostics, err := GenerateWithOptions(sources, "junolab.net", Options{})
+	require.NoError(t, err)
+
+	names := make([]string, 0, len(protocols))
+	for name := range protocols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		got, err := json.MarshalIndent(protocols[name], "", "    ")
+		require.NoError(t, err)
+		b.Write(got)
+	}
+	for _, d := range diagnostics {
+		b.WriteString(d.String())
+	}
+	return b.String()
+}
+
+func TestGenerateWithOptions_TimeLogicalType(t *testing.T) {
+	cfg := astparser.Config{
+		InputDir:      "fixtures_test",
+		IncludeRegexp: "struct_with_logical_types_test.go",
+	}
+	sources, err := astparser.Load(cfg)
+	require.NoError(t, err)
+
+	protocols, diagnostics, err := GenerateWithOptions(sources, "junolab.net", Options{TimeLogicalType: "timestamp-micros"})
+	require.NoError(t, err)
+	require.Empty(t, diagnostics)
+	payload := findType(t, protocols["LogicalV1"], "PayloadLogicalV1").(Record)
+	assert.Equal(t, Union{"null", LogicalType{Type: "long", LogicalType: "timestamp-micros"}}, payload.Fields[1].Type)
+	// explicit tag wins over the option
+	assert.Equal(t, Array{Type: "array", Items: LogicalType{Type: "long", LogicalType: "timestamp-millis"}}, payload.Fields[5].Type)
+	assert.Equal(t, Union{"null", LogicalType{Type: "long", LogicalType: "timestamp-millis"}}, payload.Fields[9].Type)
+}
+
+func TestGenerateWithOptions_TypeMap(t *testing.T) {
+	cfg := astparser.Config{
+		InputDir:      "fixtures_test",
+		IncludeRegexp: "struct_with_mapped_types_test.go",
+	}
+	sources, err := astparser.Load(cfg)
+	require.NoError(t, err)
+
+	typeMap, err := LoadTypeMap("fixtures_test/type_map.json")
+	require.NoError(t, err)
+
+	protocols, diagnostics, err := GenerateWithOptions(sources, "junolab.net", Options{TypeMap: typeMap})
+	require.NoError(t, err)
+	require.Empty(t, diagnostics)
+	payload := findType(t, protocols["MappedV1"], "PayloadMappedV1").(Record)
+	uuid := map[string]interface{}{"type": "string", "logicalType": "uuid"}
+	assert.Equal(t, uuid, payload.Fields[0].Type)
+	assert.Equal(t, "long", payload.Fields[1].Type)
+	assert.Equal(t, Union{"null", uuid}, payload.Fields[2].Type)
+}
+
+func TestGenerateWithOptions_InvalidTypeMap(t *testing.T) {
+	_, _, err := GenerateWithOptions(nil, "junolab.net", Options{TypeMap: map[string]interface{}{"ID": "strng"}})
+	assert.EqualError(t, err, `ID is mapped to "strng", expected primitive avro type, e.g. "string" or {"type": "string", "logicalType": "uuid"}`)
+
+	_, _, err = GenerateWithOptions(nil, "junolab.net", Options{TypeMap: map[string]interface{}{"ID": map[string]interface{}{"type": "Point"}}})
+	assert.EqualError(t, err, `ID is mapped to {"type":"Point"}, expected primitive avro type, e.g. "string" or {"type": "string", "logicalType": "uuid"}`)
+}
+
+func TestGenerateWithOptions_GoTypeProperty(t *testing.T) {
+	cfg := astparser.Config{
+		InputDir:      "fixtures_test",
+		IncludeRegexp: "struct_with_(named_types|collections)_test.go",
+	}
+	sources, err := astparser.Load(cfg)
+	require.NoError(t, err)
+
+	protocols, diagnostics, err := GenerateWithOptions(sources, "junolab.net", Options{GoTypeProperty: true})
+	require.NoError(t, err)
+	require.Empty(t, diagnostics)
+
+	payload := findType(t, protocols["NamedV1"], "PayloadNamedV1").(Record)
+	goTypes := map[string]string{}
+	for _, f := range payload.Fields {
+		goTypes[f.Name] = f.GoType
+	}
+	assert.Equal(t, map[string]string{
+		"user_id":   "UserID",
+		"distance":  "Meters",
+		"route":     "Route",
+		"labels":    "Labels",
+		"friends":   "[]UserID",
+		"distances": "Distances",
+		"stopped":   "*Meters",
+	}, goTypes)
+}
+
+func TestGenerateWithOptions_Integers(t *testing.T) {
+	cfg := astparser.Config{
+		InputDir:      "fixtures_test",
+		IncludeRegexp: "struct_with_unsigned_test.go",
+	}
+	sources, err := astparser.Load(cfg)
+	require.NoError(t, err)
+
+	uint64Decimal := LogicalType{Type: "bytes", LogicalType: "decimal", Precision: 20}
+	tests := []struct {
+		name        string
+		opts        Options
+		types       []interface{}
+		diagnostics []string
+	}{
+		{
+			name:  "strict",
+			opts:  Options{},
+			types: []interface{}{"int", "long", "long", Union{"null", "long"}, "long", Map{Type: "map", Values: "long"}},
+			diagnostics: []string{
+				`fixtures_test/struct_with_unsigned_test.go:10:2: UnsignedV1.Big: warning: uint64 is mapped to long which overflows above 2^63-1`,
+				`fixtures_test/struct_with_unsigned_test.go:11:2: UnsignedV1.Size: warning: uint is mapped to long which overflows above 2^63-1`,
+				`fixtures_test/struct_with_unsigned_test.go:12:2: UnsignedV1.Counter: warning: uint64 is mapped to long which overflows above 2^63-1`,
+				`fixtures_test/struct_with_unsigned_test.go:13:2: UnsignedV1.Totals: warning: uint64 is mapped to long which overflows above 2^63-1`,
+			},
+		},
+		{
+			name:  "decimal",
+			opts:  Options{Uint64Type: Uint64Decimal},
+			types: []interface{}{"int", "long", uint64Decimal, Union{"null", uint64Decimal}, uint64Decimal, Map{Type: "map", Values: uint64Decimal}},
+		},
+		{
+			name:  "string",
+			opts:  Options{Uint64Type: Uint64String},
+			types: []interface{}{"int", "long", "string", Union{"null", "string"}, "string", Map{Type: "map", Values: "string"}},
+		},
+		{
+			name:  "lossy",
+			opts:  Options{LossyIntegers: true, Uint64Type: Uint64String},
+			types: []interface{}{"int", "int", "long", Union{"null", "long"}, "long", Map{Type: "map", Values: "long"}},
+			diagnostics: []string{
+				`fixtures_test/struct_with_unsigned_test.go:9:2: UnsignedV1.Medium: warning: uint32 is mapped to int which overflows above 2^31-1`,
+				`fixtures_test/struct_with_unsigned_test.go:10:2: UnsignedV1.Big: warning: uint64 is mapped to long which overflows above 2^63-1`,
+				`fixtures_test/struct_with_unsigned_test.go:11:2: UnsignedV1.Size: warning: uint is mapped to long which overflows above 2^63-1`,
+				`fixtures_test/struct_with_unsigned_test.go:12:2: UnsignedV1.Counter: warning: uint64 is mapped to long which overflows above 2^63-1`,
+				`fixtures_test/struct_with_unsigned_test.go:13:2: UnsignedV1.Totals: warning: uint64 is mapped to long which overflows above 2^63-1`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			protocols, diagnostics, err := GenerateWithOptions(sources, "junolab.net", tt.opts)
+			require.NoError(t, err)
+
+			got := make([]string, 0, len(diagnostics))
+			for _, d := range diagnostics {
+				got = append(got, d.String())
+			}
+			assert.Equal(t, tt.diagnostics, nilIfEmpty(got))
+
+			payload := findType(t, protocols["UnsignedV1"], "PayloadUnsignedV1").(Record)
+			types := make([]interface{}, 0, len(payload.Fields))
+			for _, f := range payload.Fields {
+				types = append(types, f.Type)
+			}
+			assert.Equal(t, tt.types, types)
+		})
+	}
+}
+
+func TestGenerateWithOptions_Decimal(t *testing.T) {
+	decimal := astparser.TypeCustom{Name: "Decimal", Package: "github.com/shopspring/decimal"}
+	libDecimal := astparser.TypeCustom{Name: "Decimal", Package: "junolab.net/lib_api/decimal"}
+	amount := astparser.TypeCustom{Name: "Amount", Package: "junolab.net/lib_api/money"}
+	sources := map[string]astparser.ParsedFile{
+		"decimal.go": {
+			Structs: []astparser.StructDef{{
+				Name: "DecimalV1",
+				Fields: []astparser.FieldDef{
+					{FieldName: "Price", JsonName: "price", FieldType: decimal, Tag: `json:"price" avro:"precision=12,scale=2"`},
+					{FieldName: "Amount", JsonName: "amount", FieldType: amount, Tag: `json:"amount" avro:"precision=20"`},
+					{FieldName: "Fee", JsonName: "fee", FieldType: libDecimal, Tag: `json:"fee" avro:"precision=10,scale=2"`},
+				},
+			}},
+		},
+	}
+	typeMap := map[string]interface{}{
+		"junolab.net/lib_api/money.Amount": map[string]interface{}{"type": "bytes", "logicalType": "decimal"},
+	}
+
+	protocols, diagnostics, err := GenerateWithOptions(sources, "junolab.net", Options{TypeMap: typeMap})
+	require.NoError(t, err)
+	require.Empty(t, diagnostics)
+
+	payload := findType(t, protocols["DecimalV1"], "PayloadDecimalV1").(Record)
+	assert.Equal(t, LogicalType{Type: "bytes", LogicalType: "decimal", Precision: 12, Scale: 2}, payload.Fields[0].Type)
+	assert.Equal(t, map[string]interface{}{"type": "bytes", "logicalType": "decimal", "precision": 20, "scale": 0}, payload.Fields[1].Type)
+	assert.Equal(t, LogicalType{Type: "bytes", LogicalType: "decimal", Precision: 10, Scale: 2}, payload.Fields[2].Type)
+}
+
+func TestGenerateWithOptions_LoadedPackages(t *testing.T) {
+	sources := map[string]astparser.ParsedFile{
+		"buffer.go": {
+			Structs: []astparser.StructDef{{
+				Name: "BufferV1",
+				Fields: []astparser.FieldDef{
+					{FieldName: "Buffer", JsonName: "buffer", FieldType: astparser.TypeCustom{Name: "Buffer", Package: "bytes"}},
+				},
+			}},
+		},
+	}
+
+	_, diagnostics, err := GenerateWithOptions(sources, "junolab.net", Options{})
+	require.NoError(t, err)
+	require.Len(t, diagnostics, 1)
+	assert.Equal(t, "type bytes.Buffer is not declared in the parsed sources, load it with -load=packages or map it with -type-map", diagnostics[0].Message)
+
+	_, diagnostics, err = GenerateWithOptions(sources, "junolab.net", Options{LoadedPackages: true})
+	require.NoError(t, err)
+	require.Len(t, diagnostics, 1)
+	assert.Equal(t, "type bytes.Buffer is not declared in the loaded packages, map it with -type-map", diagnostics[0].Message)
+}
+
+func TestGenerateWithOptions_EnumConstants(t *testing.T) {
+	status := astparser.TypeDef{Name: "Status", Type: astparser.TypeSimple{Name: "string"}}
+	other := []astparser.ConstantDef{
+		{Name: "StatusOpen", Value: "open", Type: "Status", TypePackage: "github.com/acme/other"},
+		{Name: "StatusClosed", Value: "closed", Type: "Status", TypePackage: "github.com/acme/other"},
+	}
+	sources := map[string]astparser.ParsedFile{
+		"status.go": {
+			Structs: []astparser.StructDef{{
+				Name: "StatusV1",
+				Fields: []astparser.FieldDef{
+					{FieldName: "Status", JsonName: "status", FieldType: astparser.TypeCustom{Name: "Status"}},
+				},
+			}},
+			Types:     []astparser.TypeDef{status},
+			Constants: other,
+		},
+	}
+
+	// constants of other.Status do not make local Status an enum
+	protocols, diagnostics, err := GenerateWithOptions(sources, "junolab.net", Options{})
+	require.NoError(t, err)
+	require.Empty(t, diagnostics)
+	payload := findType(t, protocols["StatusV1"], "PayloadStatusV1").(Record)
+	assert.Equal(t, "string", payload.Fields[0].Type)
+
+	file := sources["status.go"]
+	file.Constants = append(other,
+		astparser.ConstantDef{Name: "StatusActive", Value: "active", Type: "Status"},
+		astparser.ConstantDef{Name: "StatusDone", Value: "done", Type: "Status"},
+	)
+	sources["status.go"] = file
+	protocols, diagnostics, err = GenerateWithOptions(sources, "junolab.net", Options{})
+	require.NoError(t, err)
+	require.Empty(t, diagnostics)
+	enum := findType(t, protocols["StatusV1"], "Status").(Enum)
+	assert.Equal(t, []string{"active", "done"}, enum.Symbols)
+}
+
+func TestGenerateWithOptions_Diagnostics(t *testing.T) {
+	cfg := astparser.Config{
+		InputDir:      "fixtures_test/diagnostics",
+		IncludeRegexp: "test.go",
+	}
+	sources, err := astparser.Load(cfg)
+	require.NoError(t, err)
+
+	protocols, diagnostics, err := GenerateWithOptions(sources, "junolab.net", Options{})
+	require.NoError(t, err)
+
+	got := make([]string, 0, len(diagnostics))
+	for _, d := range diagnostics {
+		got = append(got, d.String())
+	}
+	assert.Equal(t, []string{
+		`fixtures_test/diagnostics/invalid_test.go:6:2: Status.StatusInProgress: error: "in-progress" is not a valid avro enum symbol`,
+		`fixtures_test/diagnostics/invalid_test.go:14:2: InvalidV1.Date: error: logical type date could not be applied to string`,
+		`fixtures_test/diagnostics/invalid_test.go:16:2: InvalidV1.Dash: error: "-" is not a valid avro field name`,
+		`fixtures_test/diagnostics/invalid_test.go:17:2: InvalidV1.Order: error: unknown avro tag key "sort"`,
+		`fixtures_test/diagnostics/invalid_test.go:18:2: InvalidV1.Other: error: duplicate avro field name int`,
+		`fixtures_test/diagnostics/invalid_test.go:19:2: InvalidV1.Limit: error: default 3000000000 does not match type int`,
+		`fixtures_test/diagnostics/invalid_test.go:21:2: InvalidV1.State: error: default "unknown" does not match type Status`,
+		`fixtures_test/diagnostics/invalid_test.go:22:2: InvalidV1.Hash: error: length of byte array could not be evaluated, load sources with packages loader`,
+		`fixtures_test/diagnostics/invalid_test.go:23:2: InvalidV1.Price: error: decimal precision is required, set it with avro:"precision=N,scale=M" tag`,
+		`fixtures_test/diagnostics/invalid_test.go:24:2: InvalidV1.Small: error: decimal precision 10 does not fit in fixed of 2 bytes, max precision is 4`,
+		`fixtures_test/diagnostics/invalid_test.go:25:2: InvalidV1.Count: error: precision and scale could be set for decimal fields only`,
+		`fixtures_test/diagnostics/invalid_test.go:26:2: InvalidV1.Ref: error: logical type uuid could not be applied to int`,
+		`fixtures_test/diagnostics/invalid_test.go:27:2: InvalidV1.Tree: error: type Tree refers to itself, recursive named types are not supported`,
+		`fixtures_test/diagnostics/invalid_test.go:36:2: Loop.Next: error: record Loop refers to itself through non-nullable field and could never be serialized, make the field nullable`,
+		`fixtures_test/diagnostics/invalid_test.go:40:2: Ring.Link: error: records Link, Ring refer to each other through non-nullable fields only and could never be serialized, make one of the fields nullable`,
+		`fixtures_test/diagnostics/invalid_test.go:44:2: Link.Ring: error: records Link, Ring refer to each other through non-nullable fields only and could never be serialized, make one of the fields nullable`,
+		`fixtures_test/diagnostics/unresolved_test.go:8:2: UnresolvedV1.Buffer: error: type bytes.Buffer is not declared in the parsed sources, load it with -load=packages or map it with -type-map`,
+		`fixtures_test/diagnostics/unresolved_test.go:9:2: UnresolvedV1.Complex: error: unsupported go type complex64`,
+		`fixtures_test/diagnostics/unresolved_test.go:10:2: UnresolvedV1.Pointer: warning: uintptr is mapped to long which overflows above 2^63-1`,
+		`fixtures_test/diagnostics/unresolved_test.go:11:2: UnresolvedV1.Typo: error: unknown avro type strng, expected primitive type or name of the declared record or enum`,
+		`fixtures_test/diagnostics/unresolved_test.go:12:2: UnresolvedV1.Done: error: unsupported go type chan bool`,
+		`fixtures_test/diagnostics/unresolved_test.go:13:2: UnresolvedV1.Any: error: unsupported go type interface{}`,
+		`fixtures_test/diagnostics/unresolved_test.go:14:2: UnresolvedV1.Length: error: type Meters is resolved to its underlying type and has no avro name, set the underlying avro type`,
+	}, got)
+
+	// invalid field is skipped
+	payload := findType(t, protocols["InvalidV1"], "PayloadInvalidV1").(Record)
+	require.Len(t, payload.Fields, 2)
+	assert.Equal(t, "status", payload.Fields[0].Name)
+	assert.Equal(t, "int", payload.Fields[1].Name)
+}
+
+func TestGenerateWithOptions_WarnUntagged(t *testing.T) {
+	cfg := astparser.Config{
+		InputDir:      "fixtures_test",
+		IncludeRegexp: "struct_with_ignored_fields_test.go",
+	}
+	sources, err := astparser.Load(cfg)
+	require.NoError(t, err)
+
+	_, diagnostics, err := GenerateWithOptions(sources, "junolab.net", Options{})
+	require.NoError(t, err)
+	assert.Empty(t, diagnostics)
+
+	_, diagnostics, err = GenerateWithOptions(sources, "junolab.net", Options{WarnUntagged: true})
+	require.NoError(t, err)
+
+	got := make([]string, 0, len(diagnostics))
+	for _, d := range diagnostics {
+		got = append(got, d.String())
+	}
+	assert.Equal(t, []string{
+		`fixtures_test/struct_with_ignored_fields_test.go:18:2: IgnoredV1.Untagged: warning: field has no json tag and is named Untagged after the go field`,
+	}, got)
+}
+
+func TestGenerateWithOptions_Conflicts(t *testing.T) {
+	cfg := astparser.Config{InputDir: "fixtures_test/conflict"}
+	sources, err := astparser.LoadPackages(cfg)
+	require.NoError(t, err)
+
+	_, diagnostics, err := GenerateWithOptions(sources, "junolab.net", Options{})
+	require.NoError(t, err)
+	require.Len(t, diagnostics, 2)
+	for _, d := range diagnostics {
+		assert.Equal(t, SeverityError, d.Severity)
+		assert.Equal(t, "Point", d.Struct)
+	}
+	assert.Equal(t, "conflict.go", filepath.Base(diagnostics[0].Pos.Filename))
+	assert.Contains(t, diagnostics[0].Message, "typed/geo/geo.go:7:6")
+	assert.Equal(t, "geo.go", filepath.Base(diagnostics[1].Pos.Filename))
+	assert.Contains(t, diagnostics[1].Message, "conflict/conflict.go:7:6")
+
+	_, _, err = GenerateWithOptions(sources, "", Options{DisambiguateNamespaces: true})
+	assert.Error(t, err)
+
+	protocols, diagnostics, err := GenerateWithOptions(sources, "junolab.net", Options{DisambiguateNamespaces: true})
+	require.NoError(t, err)
+	require.Empty(t, diagnostics)
+
+	remote := "github_com.gojuno.genavro.avro.fixtures_test.typed.geo.Point"
+	payload := findType(t, protocols["ConflictV1"], "PayloadConflictV1").(Record)
+	assert.Equal(t, "Point", payload.Fields[0].Type)
+	assert.Equal(t, remote, payload.Fields[1].Type)
+	assert.Len(t, findType(t, protocols["ConflictV1"], "Point").(Record).Fields, 2)
+	assert.Len(t, findType(t, protocols["ConflictV1"], remote).(Record).Fields, 4)
+
+	// types of the protocol namespace are referred from the disambiguated record by full name
+	p := protocols["ConflictV1"]
+	assert.Equal(t, "junolab.net.Kind", findType(t, p, remote).(Record).Fields[2].Type)
+	defined := map[string]bool{}
+	for _, tpe := range p.Types {
+		assertNamesResolved(t, jsonValue(t, tpe), p.Namespace, defined)
+	}
+	schema, err := Schema(protocols, "ConflictV1")
+	require.NoError(t, err)
+	assertNamesResolved(t, jsonValue(t, schema), "", map[string]bool{})
+
+	fingerprints, err := ProtocolFingerprints(p)
+	require.NoError(t, err)
+	assert.Contains(t, fingerprints[remote].CanonicalForm, `{"name":"kind","type":{"name":"junolab.net.Kind"`)
+	idl, err := IDL(p)
+	require.NoError(t, err)
+	assert.Contains(t, string(idl), "junolab.net.Kind kind;")
+}
+
+func TestGenerateWithOptions_Events(t *testing.T) {
+	cfg := astparser.Config{
+		InputDir:      "fixtures_test/events",
+		IncludeRegexp: "test.go",
+	}
+	sources, err := astparser.Load(cfg)
+	require.NoError(t, err)
+
+	events, err := Events(sources, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ConfigV2", "OrderV1", "RideFinished"}, eventNames(events))
+
+	opts := Options{EventRegexp: `^Order.*V\d+$`, EventMarker: "Event"}
+	events, err = Events(sources, opts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"OrderV1", "PaymentDone", "RideFinished"}, eventNames(events))
+	assert.Equal(t, []string{
+		`regexp ^Order.*V\d+$`,
+		"embedded marker Event",
+		"//genavro:event directive",
+	}, []string{events[0].Reason, events[1].Reason, events[2].Reason})
+
+	protocols, diagnostics, err := GenerateWithOptions(sources, "junolab.net", opts)
+	require.NoError(t, err)
+	require.Empty(t, diagnostics)
+	require.Len(t, protocols, 3)
+
+	// marker is not generated and directive is not a part of the doc
+	payment := findType(t, protocols["PaymentDone"], "PayloadPaymentDone").(Record)
+	require.Len(t, payment.Fields, 1)
+	assert.Equal(t, "amount", payment.Fields[0].Name)
+	ride := findType(t, protocols["RideFinished"], "PayloadRideFinished").(Record)
+	assert.Empty(t, ride.Doc)
+	// helper struct is a record of the event
+	findType(t, protocols["OrderV1"], "ConfigV2")
+}
+
+func TestGenerateWithOptions_EventsWithoutRegexp(t *testing.T) {
+	cfg := astparser.Config{
+		InputDir:      "fixtures_test/events",
+		IncludeRegexp: "test.go",
+		Interfaces:    []string{"Tracked"},
+	}
+	sources, err := astparser.LoadPackages(cfg)
+	require.NoError(t, err)
+
+	events, err := Events(sources, Options{DisableEventRegexp: true, EventMarker: "Tracked"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"DriverAssigned", "RideFinished"}, eventNames(events))
+	assert.Equal(t, "marker interface Tracked", events[0].Reason)
+
+	events, err = Events(sources, Options{DisableEventRegexp: true, EventMarker: "Event"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"PaymentDone", "RideFinished"}, eventNames(events))
+
+	// marker interface is not checked without type information
+	sources, err = astparser.Load(astparser.Config{InputDir: "fixtures_test/events", IncludeRegexp: "test.go"})
+	require.NoError(t, err)
+	events, err = Events(sources, Options{DisableEventRegexp: true, EventMarker: "Tracked"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"RideFinished"}, eventNames(events))
+}
+
+func TestGenerateWithOptions_Envelope(t *testing.T) {
+	cfg := astparser.Config{
+		InputDir:      "fixtures_test/envelope",
+		IncludeRegexp: "test.go",
+	}
+	sources, err := astparser.Load(cfg)
+	require.NoError(t, err)
+
+	// envelope struct is selected by directive
+	protocols, diagnostics, err := GenerateWithOptions(sources, "junolab.net", Options{PayloadField: "body"})
+	require.NoError(t, err)
+	require.Empty(t, diagnostics)
+	require.Len(t, protocols, 1)
+	assert.Equal(t, []string{"Source", "PayloadCreatedV1", "CreatedV1"}, typeNames(protocols["CreatedV1"]))
+	envelope := findType(t, protocols["CreatedV1"], "CreatedV1").(Record)
+	assert.Equal(t, []string{"id", "created_at", "source", "body"}, fieldNames(envelope))
+	assert.Equal(t, "PayloadCreatedV1", envelope.Fields[3].Type)
+
+	protocols, _, err = GenerateWithOptions(sources, "junolab.net", Options{Envelope: EnvelopeNone})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"CreatedV1"}, typeNames(protocols["CreatedV1"]))
+	assert.Equal(t, []string{"name"}, fieldNames(findType(t, protocols["CreatedV1"], "CreatedV1").(Record)))
+
+	protocols, _, err = GenerateWithOptions(sources, "junolab.net", Options{Envelope: EnvelopeDefault})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"PayloadCreatedV1", "Auth", "CreatedV1"}, typeNames(protocols["CreatedV1"]))
+
+	schema, err := LoadEnvelope("fixtures_test/envelope/envelope.json")
+	require.NoError(t, err)
+	protocols, _, err = GenerateWithOptions(sources, "junolab.net", Options{EnvelopeSchema: schema})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"PayloadCreatedV1", "CreatedV1"}, typeNames(protocols["CreatedV1"]))
+	envelope = findType(t, protocols["CreatedV1"], "CreatedV1").(Record)
+	assert.Equal(t, []string{"trace_id", "headers", "payload"}, fieldNames(envelope))
+
+	_, _, err = GenerateWithOptions(sources, "junolab.net", Options{Envelope: "Missing"})
+	assert.EqualError(t, err, "envelope struct Missing is not found in the sources")
+
+	_, _, err = GenerateWithOptions(sources, "junolab.net", Options{EnvelopeSchema: schema, PayloadField: "headers"})
+	assert.EqualError(t, err, "envelope field headers conflicts with payload field, set other payload field name")
+
+	// envelope is renamed after the event, its own namespace would hide the payload of the protocol namespace
+	namespaced, err := LoadEnvelope("fixtures_test/envelope/envelope_namespaced.json")
+	require.NoError(t, err)
+	_, _, err = GenerateWithOptions(sources, "junolab.net", Options{EnvelopeSchema: namespaced})
+	assert.EqualError(t, err, "envelope record Envelope declares namespace com.acme, envelopes are put to the namespace of the protocol")
+	_, _, err = GenerateWithOptions(sources, "junolab.net", Options{EnvelopeSchema: &Record{Type: "record", Name: "com.acme.Envelope"}})
+	assert.EqualError(t, err, "envelope record Envelope declares namespace com.acme, envelopes are put to the namespace of the protocol")
+
+	_, diagnostics, err = GenerateWithOptions(sources, "junolab.net", Options{PayloadField: "id"})
+	require.NoError(t, err)
+	require.Len(t, diagnostics, 1)
+	assert.Equal(t, "envelope field id conflicts with payload field, set other payload field name", diagnostics[0].Message)
+}
+
+func TestLoadEnvelope(t *testing.T) {
+	cfg := astparser.Config{
+		InputDir:      "fixtures_test/envelope",
+		IncludeRegexp: "test.go",
+	}
+	sources, err := astparser.Load(cfg)
+	require.NoError(t, err)
+
+	envelope, err := LoadEnvelope("fixtures_test/envelope/envelope_named.json")
+	require.NoError(t, err)
+	meta := Record{Type: "record", Name: "ns.Meta", Fields: []Field{
+		{Name: "source", Type: Enum{Type: "enum", Name: "ns.Source", Symbols: []string{"api", "batch"}}},
+		{Name: "ts", Type: LogicalType{Type: "long", LogicalType: "timestamp-millis"}},
+	}}
+	assert.Equal(t, []Field{
+		{Name: "meta", Type: Union{"null", meta}, Default: nullDefault},
+		{Name: "tags", Type: Array{Type: "array", Items: Union{"string", "long"}}},
+		{Name: "previous", Type: Union{"null", "ns.Meta"}, Default: nullDefault},
+	}, envelope.Fields)
+
+	protocols, diagnostics, err := GenerateWithOptions(sources, "junolab.net", Options{EnvelopeSchema: envelope})
+	require.NoError(t, err)
+	require.Empty(t, diagnostics)
+	p := protocols["CreatedV1"]
+
+	schema, err := Schema(protocols, "CreatedV1")
+	require.NoError(t, err)
+	data, err := json.Marshal(schema)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"type":["null",{"type":"record","name":"ns.Meta","fields":[{"name":"source","type":{"type":"enum","name":"ns.Source"`)
+
+	idl, err := IDL(p)
+	require.NoError(t, err)
+	assert.Contains(t, string(idl), `@namespace("ns") record Meta {`)
+	assert.Contains(t, string(idl), `union { null, ns.Meta } meta = null;`)
+	assert.Contains(t, string(idl), `array<union { string, long }> tags;`)
+
+	fingerprints, err := ProtocolFingerprints(p)
+	require.NoError(t, err)
+	names := make([]string, 0, len(fingerprints))
+	for name := range fingerprints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{"junolab.net.CreatedV1", "junolab.net.PayloadCreatedV1", "ns.Meta", "ns.Source"}, names)
+}
+
+func typeNames(p Protocol) []string {
+	names := make([]string, 0, len(p.Types))
+	for _, tpe := range p.Types {
+		names = append(names, avroSchemaName(tpe))
+	}
+	return names
+}
+
+func fieldNames(r Record) []string {
+	names := make([]string, 0, len(r.Fields))
+	for _, f := range r.Fields {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+func eventNames(events []Event) []string {
+	names := make([]string, 0, len(events))
+	for _, e := range events {
+		names = append(names, e.Name)
+	}
+	return names
+}
+
+func TestGenerateWithOptions_InvalidOptions(t *testing.T) {
+	// logical types of other primitive types could not be applied to time fields
+	for _, logical := range []string{"date-time", "uuid", "decimal"} {
+		_, _, err := GenerateWithOptions(nil, "junolab.net", Options{TimeLogicalType: logical})
+		assert.EqualError(t, err, "unsupported time logical type "+logical+", expected one of date, time-millis, time-micros, timestamp-millis, timestamp-micros")
+	}
+
+	_, _, err := GenerateWithOptions(nil, "junolab.net", Options{Uint64Type: "double"})
+	assert.EqualError(t, err, "unsupported uint64 type double, expected one of long, decimal, string")
+
+	_, _, err = GenerateWithOptions(nil, "junolab.net", Options{PayloadField: "pay-load"})
+	assert.EqualError(t, err, `"pay-load" is not a valid avro field name for payload`)
+
+	_, _, err = GenerateWithOptions(nil, "junolab.net", Options{EventRegexp: "V("})
+	assert.EqualError(t, err, "invalid event regexp V(: error parsing regexp: missing closing ): `V(`")
+}
+
+func nilIfEmpty(s []string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	return s
+}
+
+// assertNamesResolved checks that every name the json schema refers is defined before
+// with the full name avro resolves the name to in the namespace of the enclosing type.
+func assertNamesResolved(t *testing.T, schema interface{}, namespace string, defined map[string]bool) {
+	switch v := schema.(type) {
+	case string:
+		if !avroIsPrimitiveType(v) {
+			assert.True(t, defined[fullName(v, namespace)], "%s is not defined in namespace %s", v, namespace)
+		}
+	case []interface{}:
+		for _, u := range v {
+			assertNamesResolved(t, u, namespace, defined)
+		}
+	case map[string]interface{}:
+		switch v["type"] {
+		case "record", "enum", "fixed":
+			name := definedName(v, namespace)
+			defined[name] = true
+			for _, f := range fieldsOf(v) {
+				assertNamesResolved(t, f["type"], namespaceOf(name), defined)
+			}
+		case "array":
+			assertNamesResolved(t, v["items"], namespace, defined)
+		case "map":
+			assertNamesResolved(t, v["values"], namespace, defined)
+		default:
+			assertNamesResolved(t, v["type"], namespace, defined)
+		}
+	}
+}
+
+func findType(t *testing.T, p Protocol, name string) interface{} {
+	for _, tpe := range p.Types {
+		if avroSchemaName(tpe) == name {
+			return tpe
+		}
+	}
+	require.FailNow(t, "type not found", "type %s not found in protocol %s", name, p.Protocol)
+	return nil
+}
@@ -0,0 +1,301 @@
+package avro
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// idlKeywords are reserved words of avro IDL, identifiers matching them are escaped with backticks.
+var idlKeywords = map[string]bool{
+	"array": true, "boolean": true, "bytes": true, "date": true, "decimal": true, "double": true,
+	"enum": true, "error": true, "false": true, "fixed": true, "float": true, "idl": true,
+	"import": true, "int": true, "local_timestamp_ms": true, "long": true, "map": true,
+	"null": true, "oneway": true, "protocol": true, "record": true, "schema": true, "string": true,
+	"throws": true, "time_ms": true, "timestamp_ms": true, "true": true, "union": true,
+	"uuid": true, "void": true,
+}
+
+// idlLogicalTypes are logical types with their own IDL keywords.
+var idlLogicalTypes = map[string]string{
+	logicalDate:            "date",
+	logicalTimeMillis:      "time_ms",
+	logicalTimestampMillis: "timestamp_ms",
+	logicalUUID:            "uuid",
+}
+
+const idlIndent = "    "
+
+// IDL formats the protocol as avro IDL, e.g.
+//
+//	@namespace("junolab.net")
+//	protocol RideV1 {
+//	    record PayloadRideV1 {
+//	        union { null, string } comment = null;
+//	    }
+//	}
+//
+// Named types defined inline are declared before the types they are defined in,
+// records of a cycle are declared in order of their definitions in the protocol starting from the entry record.
+func IDL(p Protocol) ([]byte, error) {
+	schemas := map[string]interface{}{}
+	register := func(name string, schema interface{}) {
+		if _, ok := schemas[name]; !ok {
+			schemas[name] = schema
+		}
+	}
+	var names []string
+	seen := map[string]bool{}
+	for _, tpe := range p.Types {
+		tpe = qualifiedNames(tpe, p.Namespace)
+		namedTypes(tpe, register)
+		idlOrder(tpe, seen, &names)
+	}
+	// declarations of other namespaces are annotated, names inside them are resolved against their namespace
+	types := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		types = append(types, relativeNames(schemas[name], p.Namespace))
+	}
+
+	var b bytes.Buffer
+	if p.Namespace != "" {
+		fmt.Fprintf(&b, "@namespace(%s)\n", idlJSON(p.Namespace))
+	}
+	writeIDLDoc(&b, "", p.Doc)
+	fmt.Fprintf(&b, "protocol %s {\n", idlName(p.Protocol))
+	for i, tpe := range types {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		if err := writeIDLDeclaration(&b, tpe); err != nil {
+			return nil, err
+		}
+	}
+	b.WriteString("}\n")
+	return b.Bytes(), nil
+}
+
+// idlOrder appends names of the named types the schema defines in order of their IDL declarations.
+// Record is declared before the types defined in it if they refer it back, e.g. the entry record of a cycle,
+// so the declarations follow the protocol and the only forward reference closes the cycle.
+func idlOrder(schema interface{}, seen map[string]bool, names *[]string) {
+	switch v := schema.(type) {
+	case Record:
+		if seen[v.Name] {
+			return
+		}
+		first := referredBack(v)
+		if first {
+			seen[v.Name] = true
+			*names = append(*names, v.Name)
+		}
+		for _, f := range v.Fields {
+			idlOrder(f.Type, seen, names)
+		}
+		if !first {
+			seen[v.Name] = true
+			*names = append(*names, v.Name)
+		}
+	case Enum, Fixed:
+		if name := avroSchemaName(v); !seen[name] {
+			seen[name] = true
+			*names = append(*names, name)
+		}
+	case Array:
+		idlOrder(v.Items, seen, names)
+	case Map:
+		idlOrder(v.Values, seen, names)
+	case Union:
+		for _, u := range v {
+			idlOrder(u, seen, names)
+		}
+	}
+}
+
+// referredBack checks if records defined inline in the record refer to it.
+func referredBack(r Record) bool {
+	var found bool
+	check := func(_ string, schema interface{}) {
+		inner, ok := schema.(Record)
+		if !ok || inner.Name == r.Name {
+			return
+		}
+		for _, f := range inner.Fields {
+			for _, name := range avroDepNames(f.Type) {
+				found = found || name == r.Name
+			}
+		}
+	}
+	for _, f := range r.Fields {
+		namedTypes(f.Type, check)
+	}
+	return found
+}
+
+func writeIDLDeclaration(b *bytes.Buffer, schema interface{}) error {
+	switch v := schema.(type) {
+	case Record:
+		writeIDLDoc(b, idlIndent, v.Doc)
+		fmt.Fprintf(b, "%s%srecord %s {\n", idlIndent, idlNamespace(v.Name, v.Namespace), idlName(shortName(v.Name)))
+		for _, f := range v.Fields {
+			if err := writeIDLField(b, f); err != nil {
+				return fmt.Errorf("%s.%s: %v", v.Name, f.Name, err)
+			}
+		}
+		fmt.Fprintf(b, "%s}\n", idlIndent)
+	case Enum:
+		writeIDLDoc(b, idlIndent, v.Doc)
+		symbols := make([]string, 0, len(v.Symbols))
+		for _, s := range v.Symbols {
+			symbols = append(symbols, idlName(s))
+		}
+		fmt.Fprintf(b, "%s%senum %s {\n", idlIndent, idlNamespace(v.Name, v.Namespace), idlName(shortName(v.Name)))
+		fmt.Fprintf(b, "%s%s%s\n", idlIndent, idlIndent, strings.Join(symbols, ", "))
+		fmt.Fprintf(b, "%s}", idlIndent)
+		if v.Default != "" {
+			fmt.Fprintf(b, " = %s;", idlName(v.Default))
+		}
+		b.WriteString("\n")
+	case Fixed:
+		var annotations string
+		if v.LogicalType != "" {
+			annotations = fmt.Sprintf("@logicalType(%s) ", idlJSON(v.LogicalType))
+		}
+		if v.LogicalType == logicalDecimal {
+			annotations += fmt.Sprintf("@precision(%d) @scale(%d) ", v.Precision, v.Scale)
+		}
+		fmt.Fprintf(b, "%s%s%sfixed %s(%d);\n", idlIndent, annotations, idlNamespace(v.Name, v.Namespace), idlName(shortName(v.Name)), v.Size)
+	default:
+		return fmt.Errorf("unsupported named type %T", schema)
+	}
+	return nil
+}
+
+// writeIDLField writes field as `type @annotations name = default;`.
+func writeIDLField(b *bytes.Buffer, f Field) error {
+	t, err := idlType(f.Type)
+	if err != nil {
+		return err
+	}
+
+	parts := []string{t}
+	if f.Order != "" {
+		parts = append(parts, fmt.Sprintf("@order(%s)", idlJSON(f.Order)))
+	}
+	if len(f.Aliases) > 0 {
+		parts = append(parts, fmt.Sprintf("@aliases(%s)", idlJSON(f.Aliases)))
+	}
+	if f.GoType != "" {
+		parts = append(parts, fmt.Sprintf("@goType(%s)", idlJSON(f.GoType)))
+	}
+	parts = append(parts, idlName(f.Name))
+	if len(f.Default) > 0 {
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, f.Default); err != nil {
+			return fmt.Errorf("invalid default %s: %v", f.Default, err)
+		}
+		parts = append(parts, "=", compact.String())
+	}
+
+	writeIDLDoc(b, idlIndent+idlIndent, f.Doc)
+	fmt.Fprintf(b, "%s%s%s;\n", idlIndent, idlIndent, strings.Join(parts, " "))
+	return nil
+}
+
+// idlType returns IDL of the field type, named types are referred by name.
+func idlType(t interface{}) (string, error) {
+	switch v := t.(type) {
+	case string:
+		if avroIsPrimitiveType(v) {
+			return v, nil
+		}
+		return idlName(v), nil
+	case Array:
+		items, err := idlType(v.Items)
+		if err != nil {
+			return "", err
+		}
+		return "array<" + items + ">", nil
+	case Map:
+		values, err := idlType(v.Values)
+		if err != nil {
+			return "", err
+		}
+		return "map<" + values + ">", nil
+	case Union:
+		types := make([]string, 0, len(v))
+		for _, u := range v {
+			s, err := idlType(u)
+			if err != nil {
+				return "", err
+			}
+			types = append(types, s)
+		}
+		return "union { " + strings.Join(types, ", ") + " }", nil
+	case LogicalType:
+		if keyword, ok := idlLogicalTypes[v.LogicalType]; ok && v.Type == avroLogicalTypes[v.LogicalType] {
+			return keyword, nil
+		}
+		if v.LogicalType == logicalDecimal && v.Type == "bytes" {
+			return fmt.Sprintf("decimal(%d, %d)", v.Precision, v.Scale), nil
+		}
+		return fmt.Sprintf("@logicalType(%s) %s", idlJSON(v.LogicalType), v.Type), nil
+	case map[string]interface{}:
+		// schemas from the type map are primitive types with properties
+		base, ok := v["type"].(string)
+		if !ok {
+			return "", fmt.Errorf("unsupported schema %v", v)
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			if k != "type" {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		var annotations []string
+		for _, k := range keys {
+			annotations = append(annotations, fmt.Sprintf("@%s(%s)", k, idlJSON(v[k])))
+		}
+		return strings.Join(append(annotations, base), " "), nil
+	default:
+		return "", fmt.Errorf("unsupported type %T", t)
+	}
+}
+
+func writeIDLDoc(b *bytes.Buffer, indent, doc string) {
+	if doc == "" {
+		return
+	}
+	fmt.Fprintf(b, "%s/** %s */\n", indent, strings.Replace(doc, "*/", "*\\/", -1))
+}
+
+// idlNamespace returns namespace annotation of the type with full name or explicit namespace.
+func idlNamespace(name, namespace string) string {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		namespace = name[:i]
+	}
+	if namespace == "" {
+		return ""
+	}
+	return fmt.Sprintf("@namespace(%s) ", idlJSON(namespace))
+}
+
+func shortName(name string) string {
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// idlName escapes identifier matching IDL keyword.
+func idlName(name string) string {
+	if idlKeywords[name] {
+		return "`" + name + "`"
+	}
+	return name
+}
+
+func idlJSON(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
@@ -0,0 +1,338 @@
+package avro
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"testing"
+	"text/scanner"
+
+	"github.com/gojuno/genavro/astparser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIDL(t *testing.T) {
+	cfg := astparser.Config{
+		InputDir:      "fixtures_test",
+		IncludeRegexp: "test.go",
+	}
+	sources, err := astparser.Load(cfg)
+	require.NoError(t, err)
+
+	for name, protocol := range Generate(sources, "junolab.net") {
+		got, err := IDL(protocol)
+		require.NoError(t, err)
+		want, err := ioutil.ReadFile(
+			fmt.Sprintf("fixtures_test/%s.avdl", name))
+		require.NoError(t, err)
+		assert.Equal(t, string(want), string(got))
+	}
+}
+
+func TestIDL_Escaping(t *testing.T) {
+	got, err := IDL(Protocol{
+		Namespace: "junolab.net",
+		Protocol:  "EscapedV1",
+		Types: []interface{}{
+			Enum{Type: "enum", Name: "a.b.Kind", Symbols: []string{"record", "other"}, Default: "record"},
+			Record{Type: "record", Name: "EscapedV1", Doc: "has */ inside", Fields: []Field{
+				{Name: "error", Type: "a.b.Kind", Order: "ignore", Aliases: []string{"err"}},
+				{Name: "micros", Type: newLogicalType(logicalTimestampMicros)},
+			}},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `@namespace("junolab.net")
+protocol EscapedV1 {
+    @namespace("a.b") enum Kind {
+        `+"`record`"+`, other
+    } = `+"`record`"+`;
+
+    /** has *\/ inside */
+    record EscapedV1 {
+        a.b.Kind @order("ignore") @aliases(["err"]) `+"`error`"+`;
+        @logicalType("timestamp-micros") long micros;
+    }
+}
+`, string(got))
+}
+
+func TestIDL_RoundTrip(t *testing.T) {
+	cfg := astparser.Config{
+		InputDir:      "fixtures_test",
+		IncludeRegexp: "test.go",
+	}
+	sources, err := astparser.Load(cfg)
+	require.NoError(t, err)
+
+	for name, protocol := range Generate(sources, "junolab.net") {
+		want := map[string]interface{}{}
+		for _, tpe := range protocol.Types {
+			namedTypes(tpe, func(name string, schema interface{}) { want[name] = schema })
+		}
+
+		data, err := IDL(protocol)
+		require.NoError(t, err)
+		r := newIDLReader(t, data)
+		got := r.protocol()
+		assert.Equal(t, jsonValue(t, want), jsonValue(t, got), name)
+
+		// names are resolved in order of declarations, only a cycle needs a forward reference
+		if name == "RecursiveV1" {
+			assert.Equal(t, []string{"Node", "Employee", "Department"}, r.order[:3])
+			assert.Equal(t, []string{"Employee -> Department"}, r.forward)
+		} else {
+			assert.Empty(t, r.forward, name)
+		}
+	}
+}
+
+// idlReader reads named types of avro IDL written by IDL, it resolves names in order of declarations
+// and collects forward references to the types declared later.
+type idlReader struct {
+	t       *testing.T
+	s       scanner.Scanner
+	src     []byte
+	tok     rune
+	doc     string
+	types   map[string]interface{}
+	order   []string
+	forward []string
+	current string
+}
+
+func newIDLReader(t *testing.T, src []byte) *idlReader {
+	r := &idlReader{t: t, src: src, types: map[string]interface{}{}}
+	r.s.Init(bytes.NewReader(src))
+	r.s.Mode = scanner.ScanIdents | scanner.ScanInts | scanner.ScanStrings | scanner.ScanRawStrings | scanner.ScanComments
+	r.next()
+	return r
+}
+
+// next scans the next token keeping the doc comment right before it.
+func (r *idlReader) next() {
+	r.doc = ""
+	for {
+		r.tok = r.s.Scan()
+		if r.tok != scanner.Comment {
+			return
+		}
+		doc := strings.TrimSuffix(strings.TrimPrefix(r.s.TokenText(), "/** "), " */")
+		r.doc = strings.Replace(doc, "*\\/", "*/", -1)
+	}
+}
+
+func (r *idlReader) expect(text string) {
+	require.Equal(r.t, text, r.s.TokenText(), "at %s", r.s.Position)
+	r.next()
+}
+
+// ident reads identifier escaped with backticks if it is a keyword.
+func (r *idlReader) ident() string {
+	text := r.s.TokenText()
+	require.Contains(r.t, []rune{scanner.Ident, scanner.RawString}, r.tok, "identifier expected at %s", r.s.Position)
+	r.next()
+	return strings.Trim(text, "`")
+}
+
+// name reads possibly full name, e.g. ns.Meta.
+func (r *idlReader) name() string {
+	name := r.ident()
+	for r.tok == '.' {
+		r.next()
+		name += "." + r.ident()
+	}
+	return name
+}
+
+// raw reads json value until the token at depth 0 and returns its source.
+func (r *idlReader) raw(until rune) json.RawMessage {
+	start := r.s.Position.Offset
+	depth := 0
+	for depth > 0 || r.tok != until {
+		switch r.tok {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case scanner.EOF:
+			r.t.Fatalf("unexpected end of IDL")
+		}
+		r.next()
+	}
+	return json.RawMessage(bytes.TrimSpace(r.src[start:r.s.Position.Offset]))
+}
+
+// annotations reads @name(json) annotations.
+func (r *idlReader) annotations() map[string]interface{} {
+	annotations := map[string]interface{}{}
+	for r.tok == '@' {
+		r.next()
+		name := r.ident()
+		r.expect("(")
+		var value interface{}
+		require.NoError(r.t, json.Unmarshal(r.raw(')'), &value))
+		r.expect(")")
+		annotations[name] = value
+	}
+	return annotations
+}
+
+func (r *idlReader) protocol() map[string]interface{} {
+	r.annotations()
+	r.expect("protocol")
+	r.ident()
+	r.expect("{")
+	for r.tok != '}' {
+		r.declaration()
+	}
+	return r.types
+}
+
+func (r *idlReader) declaration() {
+	doc := r.doc
+	annotations := r.annotations()
+	kind := r.ident()
+	name := r.ident()
+	if namespace, ok := annotations["namespace"].(string); ok {
+		name = namespace + "." + name
+	}
+	r.order = append(r.order, name)
+	// record could refer itself
+	r.types[name] = nil
+	r.current = name
+
+	switch kind {
+	case "record":
+		record := map[string]interface{}{"type": "record", "name": name, "doc": doc}
+		var fields []interface{}
+		r.expect("{")
+		for r.tok != '}' {
+			fields = append(fields, r.field())
+		}
+		r.expect("}")
+		record["fields"] = fields
+		r.types[name] = record
+	case "enum":
+		enum := map[string]interface{}{"type": "enum", "name": name, "doc": doc}
+		var symbols []interface{}
+		r.expect("{")
+		for r.tok != '}' {
+			symbols = append(symbols, r.ident())
+			if r.tok == ',' {
+				r.next()
+			}
+		}
+		r.expect("}")
+		enum["symbols"] = symbols
+		if r.tok == '=' {
+			r.next()
+			enum["default"] = r.ident()
+			r.expect(";")
+		}
+		r.types[name] = enum
+	case "fixed":
+		fixed := map[string]interface{}{"type": "fixed", "name": name}
+		for k, v := range annotations {
+			if k != "namespace" {
+				fixed[k] = v
+			}
+		}
+		r.expect("(")
+		size, err := strconv.Atoi(r.s.TokenText())
+		require.NoError(r.t, err)
+		r.next()
+		r.expect(")")
+		r.expect(";")
+		fixed["size"] = size
+		r.types[name] = fixed
+	default:
+		r.t.Fatalf("unexpected declaration %s at %s", kind, r.s.Position)
+	}
+}
+
+func (r *idlReader) field() map[string]interface{} {
+	doc := r.doc
+	field := map[string]interface{}{"doc": doc, "type": r.fieldType()}
+	for k, v := range r.annotations() {
+		field[k] = v
+	}
+	field["name"] = r.ident()
+	if r.tok == '=' {
+		r.next()
+		field["default"] = r.raw(';')
+	}
+	r.expect(";")
+	return field
+}
+
+var idlKeywordTypes = map[string]interface{}{
+	"date":         newLogicalType(logicalDate),
+	"time_ms":      newLogicalType(logicalTimeMillis),
+	"timestamp_ms": newLogicalType(logicalTimestampMillis),
+	"uuid":         newLogicalType(logicalUUID),
+}
+
+func (r *idlReader) fieldType() interface{} {
+	annotations := r.annotations()
+	var t interface{}
+	switch name := r.name(); {
+	case name == "array":
+		r.expect("<")
+		t = map[string]interface{}{"type": "array", "items": r.fieldType()}
+		r.expect(">")
+	case name == "map":
+		r.expect("<")
+		t = map[string]interface{}{"type": "map", "values": r.fieldType()}
+		r.expect(">")
+	case name == "union":
+		var union []interface{}
+		r.expect("{")
+		for r.tok != '}' {
+			union = append(union, r.fieldType())
+			if r.tok == ',' {
+				r.next()
+			}
+		}
+		r.expect("}")
+		t = union
+	case name == "decimal":
+		r.expect("(")
+		precision, _ := strconv.Atoi(r.s.TokenText())
+		r.next()
+		r.expect(",")
+		scale, _ := strconv.Atoi(r.s.TokenText())
+		r.next()
+		r.expect(")")
+		t = LogicalType{Type: "bytes", LogicalType: logicalDecimal, Precision: precision, Scale: scale}
+	case idlKeywordTypes[name] != nil:
+		t = idlKeywordTypes[name]
+	case avroIsPrimitiveType(name):
+		t = name
+	default:
+		if _, ok := r.types[name]; !ok {
+			r.forward = append(r.forward, r.current+" -> "+name)
+		}
+		t = name
+	}
+
+	if len(annotations) == 0 {
+		return t
+	}
+	annotations["type"] = t
+	return annotations
+}
+
+// jsonValue converts the value to generic json value dropping empty docs.
+func jsonValue(t *testing.T, v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	data = bytes.Replace(data, []byte(`"doc":"",`), nil, -1)
+	var value interface{}
+	require.NoError(t, json.Unmarshal(data, &value))
+	return value
+}
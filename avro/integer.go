@@ -0,0 +1,92 @@
+package avro
+
+import (
+	"fmt"
+
+	"github.com/gojuno/genavro/astparser"
+)
+
+// Avro types uint64 could be mapped to, see Options.Uint64Type.
+const (
+	// Uint64Long maps uint64 to long which overflows above 2^63-1, such fields are reported with warning.
+	Uint64Long = "long"
+	// Uint64Decimal maps uint64 to decimal(20,0) bytes.
+	Uint64Decimal = "decimal"
+	// Uint64String maps uint64 to string.
+	Uint64String = "string"
+)
+
+// avroUnsignedType maps unsigned go integer to avro type wide enough to hold all its values in strict mode.
+// It returns false if go type is not an unsigned integer.
+func (g *generator) avroUnsignedType(gotype string) (interface{}, bool) {
+	switch gotype {
+	case "uint8", "byte", "uint16":
+		return "int", true
+	case "uint32":
+		if g.opts.LossyIntegers {
+			return "int", true
+		}
+		return "long", true
+	case "uint", "uint64", "uintptr":
+		if g.opts.LossyIntegers {
+			return "long", true
+		}
+		switch g.opts.Uint64Type {
+		case Uint64Decimal:
+			return LogicalType{Type: "bytes", LogicalType: logicalDecimal, Precision: 20}, true
+		case Uint64String:
+			return "string", true
+		default:
+			return "long", true
+		}
+	default:
+		return nil, false
+	}
+}
+
+// lossyIntegers reports unsigned integers of the field type which are mapped to avro types overflowing for their values.
+// Named types are followed to their underlying types.
+func (g *generator) lossyIntegers(s astparser.StructDef, f jsonField) {
+	seen := map[string]bool{}
+	var walk func(t astparser.Type)
+	walk = func(t astparser.Type) {
+		switch v := t.(type) {
+		case astparser.TypeSimple:
+			if seen[v.Name] {
+				return
+			}
+			seen[v.Name] = true
+
+			avroType, _ := g.avroUnsignedType(v.Name)
+			switch {
+			case v.Name == "uint32" && avroType == "int":
+				g.report(SeverityWarning, f.Pos, s.Name, f.FieldName, "uint32 is mapped to int which overflows above 2^31-1")
+			case (v.Name == "uint" || v.Name == "uint64" || v.Name == "uintptr") && avroType == "long":
+				g.report(SeverityWarning, f.Pos, s.Name, f.FieldName, "%s is mapped to long which overflows above 2^63-1", v.Name)
+			}
+		case astparser.TypePointer:
+			walk(v.InnerType)
+		case astparser.TypeArray:
+			walk(v.InnerType)
+		case astparser.TypeMap:
+			walk(v.ValueType)
+		case astparser.TypeCustom:
+			name := g.typeName(v)
+			if !g.isNamedType(v) || seen[name] {
+				return
+			}
+			seen[name] = true
+			walk(g.types[name].Type)
+		}
+	}
+	walk(f.FieldType)
+}
+
+func validUint64Type(t string) error {
+	switch t {
+	case Uint64Long, Uint64Decimal, Uint64String:
+		return nil
+	default:
+		return fmt.Errorf("unsupported uint64 type %s, expected one of %s, %s, %s", t, Uint64Long, Uint64Decimal, Uint64String)
+	}
+}
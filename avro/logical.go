@@ -0,0 +1,256 @@
+package avro
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/gojuno/genavro/astparser"
+)
+
+const (
+	logicalDate            = "date"
+	logicalTimeMillis      = "time-millis"
+	logicalTimeMicros      = "time-micros"
+	logicalTimestampMillis = "timestamp-millis"
+	logicalTimestampMicros = "timestamp-micros"
+	logicalUUID            = "uuid"
+	logicalDecimal         = "decimal"
+)
+
+// avroLogicalTypes maps supported logical types to the primitive types they annotate.
+var avroLogicalTypes = map[string]string{
+	logicalDate:            "int",
+	logicalTimeMillis:      "int",
+	logicalTimeMicros:      "long",
+	logicalTimestampMillis: "long",
+	logicalTimestampMicros: "long",
+	logicalUUID:            "string",
+	// decimal annotates fixed types as well, see withDecimal
+	logicalDecimal: "bytes",
+}
+
+// timeLogicalTypes are logical types time fields could be mapped to, see Options.TimeLogicalType.
+var timeLogicalTypes = map[string]bool{
+	logicalDate:            true,
+	logicalTimeMillis:      true,
+	logicalTimeMicros:      true,
+	logicalTimestampMillis: true,
+	logicalTimestampMicros: true,
+}
+
+func newLogicalType(logical string) LogicalType {
+	return LogicalType{Type: avroLogicalTypes[logical], LogicalType: logical}
+}
+
+// withLogicalType annotates primitive avro type with logical type, decimal is applied with withDecimal.
+// Nullable types, arrays and maps keep their shape and get annotated inner type.
+func withLogicalType(t interface{}, logical string) (interface{}, error) {
+	if _, ok := avroLogicalTypes[logical]; !ok || logical == logicalDecimal {
+		return nil, fmt.Errorf("unsupported logical type %s", logical)
+	}
+
+	switch v := t.(type) {
+	case Union:
+		inner, err := withLogicalType(v[1], logical)
+		if err != nil {
+			return nil, err
+		}
+		return Union{v[0], inner}, nil
+	case Array:
+		items, err := withLogicalType(v.Items, logical)
+		if err != nil {
+			return nil, err
+		}
+		return Array{Type: v.Type, Items: items}, nil
+	case Map:
+		values, err := withLogicalType(v.Values, logical)
+		if err != nil {
+			return nil, err
+		}
+		return Map{Type: v.Type, Values: values}, nil
+	case LogicalType:
+		if !canAnnotate(v.Type, logical) {
+			return nil, fmt.Errorf("logical type %s could not be applied to %s", logical, v.LogicalType)
+		}
+		return newLogicalType(logical), nil
+	case string:
+		if !canAnnotate(v, logical) {
+			return nil, fmt.Errorf("logical type %s could not be applied to %s", logical, v)
+		}
+		return newLogicalType(logical), nil
+	default:
+		return nil, fmt.Errorf("logical type %s could not be applied to %+v", logical, t)
+	}
+}
+
+// goTimeType is mapped to RFC3339 string as json serializes it, see avroFieldType.
+const goTimeType = "time.Time"
+
+// avroFieldType builds avro type of the field, time.Time annotated with integer logical type by the tag,
+// e.g. `avro:"logical=timestamp-millis"`, is generated as the primitive of the logical type instead of string.
+func (g *generator) avroFieldType(t astparser.Type, logical string) (interface{}, error) {
+	primitive := avroLogicalTypes[logical]
+	if !refersGoTime(t) || primitive != "int" && primitive != "long" {
+		return g.avroType(t)
+	}
+	return avroTimeType(t, primitive), nil
+}
+
+// avroTimeType builds avro type of time.Time, nullable, array or map of it with time represented by the primitive
+// instead of the type map schema, see refersGoTime.
+func avroTimeType(t astparser.Type, primitive string) interface{} {
+	switch v := t.(type) {
+	case astparser.TypePointer:
+		return newUnion(avroTimeType(v.InnerType, primitive))
+	case astparser.TypeArray:
+		return Array{Type: "array", Items: avroTimeType(v.InnerType, primitive)}
+	case astparser.TypeMap:
+		return Map{Type: "map", Values: avroTimeType(v.ValueType, primitive)}
+	default:
+		return primitive
+	}
+}
+
+// refersGoTime checks if go type is time.Time, nullable, array or map of it.
+func refersGoTime(t astparser.Type) bool {
+	switch v := t.(type) {
+	case astparser.TypePointer:
+		return refersGoTime(v.InnerType)
+	case astparser.TypeArray:
+		return refersGoTime(v.InnerType)
+	case astparser.TypeMap:
+		return refersGoTime(v.ValueType)
+	case astparser.TypeCustom:
+		return qualifiedTypeName(v) == goTimeType
+	default:
+		return false
+	}
+}
+
+// canAnnotate checks if primitive type could be annotated with logical type,
+// int and long are interchangeable as logical type defines the primitive.
+func canAnnotate(primitive, logical string) bool {
+	switch expected := avroLogicalTypes[logical]; expected {
+	case "int", "long":
+		return primitive == "int" || primitive == "long"
+	default:
+		return primitive == expected
+	}
+}
+
+// isDecimal checks if avro type or its inner type is decimal, e.g. decimal type from the type map.
+func (g *generator) isDecimal(t interface{}) bool {
+	switch v := t.(type) {
+	case Union:
+		return g.isDecimal(v.valueType())
+	case Array:
+		return g.isDecimal(v.Items)
+	case Map:
+		return g.isDecimal(v.Values)
+	case LogicalType:
+		return v.LogicalType == logicalDecimal
+	case map[string]interface{}:
+		return v["logicalType"] == logicalDecimal
+	case string:
+		fixed, ok := g.deps[v].schema.(Fixed)
+		return ok && fixed.LogicalType == logicalDecimal
+	default:
+		return false
+	}
+}
+
+// hasPrecision checks if decimal types of avro type have precision, e.g. uint64 mapped to decimal(20,0).
+func hasPrecision(t interface{}) bool {
+	switch v := t.(type) {
+	case Union:
+		return hasPrecision(v.valueType())
+	case Array:
+		return hasPrecision(v.Items)
+	case Map:
+		return hasPrecision(v.Values)
+	case LogicalType:
+		return v.Precision > 0
+	case map[string]interface{}:
+		_, ok := v["precision"]
+		return ok
+	default:
+		// fixed decimals are declared with precision
+		return true
+	}
+}
+
+// withDecimal annotates bytes or fixed avro type with decimal logical type of precision and scale from the tag.
+// Decimal fixed types are declared separately from plain fixed types of the same size, e.g. Fixed16Decimal10_2.
+func (g *generator) withDecimal(t interface{}, tag fieldTag) (interface{}, error) {
+	if tag.Precision <= 0 {
+		return nil, fmt.Errorf(`decimal precision is required, set it with avro:"precision=N,scale=M" tag`)
+	}
+	if tag.Scale > tag.Precision {
+		return nil, fmt.Errorf("decimal scale %d is greater than precision %d", tag.Scale, tag.Precision)
+	}
+
+	switch v := t.(type) {
+	case Union:
+		inner, err := g.withDecimal(v[1], tag)
+		if err != nil {
+			return nil, err
+		}
+		return Union{v[0], inner}, nil
+	case Array:
+		items, err := g.withDecimal(v.Items, tag)
+		if err != nil {
+			return nil, err
+		}
+		return Array{Type: v.Type, Items: items}, nil
+	case Map:
+		values, err := g.withDecimal(v.Values, tag)
+		if err != nil {
+			return nil, err
+		}
+		return Map{Type: v.Type, Values: values}, nil
+	case LogicalType:
+		if v.Type != "bytes" {
+			return nil, fmt.Errorf("logical type decimal could not be applied to %s", v.LogicalType)
+		}
+		return LogicalType{Type: "bytes", LogicalType: logicalDecimal, Precision: tag.Precision, Scale: tag.Scale}, nil
+	case map[string]interface{}:
+		if v["type"] != "bytes" {
+			return nil, fmt.Errorf("logical type decimal could not be applied to %v", v["type"])
+		}
+		schema := map[string]interface{}{}
+		for k, value := range v {
+			schema[k] = value
+		}
+		schema["logicalType"], schema["precision"], schema["scale"] = logicalDecimal, tag.Precision, tag.Scale
+		return schema, nil
+	case string:
+		if v == "bytes" {
+			return LogicalType{Type: "bytes", LogicalType: logicalDecimal, Precision: tag.Precision, Scale: tag.Scale}, nil
+		}
+		if fixed, ok := g.deps[v].schema.(Fixed); ok {
+			return g.decimalFixed(fixed.Size, tag.Precision, tag.Scale)
+		}
+		return nil, fmt.Errorf("logical type decimal could not be applied to %s", v)
+	default:
+		return nil, fmt.Errorf("logical type decimal could not be applied to %+v", t)
+	}
+}
+
+// decimalFixed declares fixed type with decimal logical type and returns its name.
+func (g *generator) decimalFixed(size, precision, scale int) (interface{}, error) {
+	// the biggest signed number of size bytes is 2^(8*size-1)-1
+	if max := int(math.Floor(float64(8*size-1) * math.Log10(2))); precision > max {
+		return nil, fmt.Errorf("decimal precision %d does not fit in fixed of %d bytes, max precision is %d", precision, size, max)
+	}
+
+	fixed := Fixed{
+		Type:        "fixed",
+		Name:        fmt.Sprintf("Fixed%dDecimal%d_%d", size, precision, scale),
+		Size:        size,
+		LogicalType: logicalDecimal,
+		Precision:   precision,
+		Scale:       scale,
+	}
+	g.deps[fixed.Name] = dep{schema: fixed}
+	return fixed.Name, nil
+}
@@ -0,0 +1,85 @@
+package avro
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/gojuno/genavro/astparser"
+)
+
+// avroNamedType resolves named go type which is not a struct or an enum to avro schema of its underlying type,
+// e.g. `type UserID string` is a string and `type Route []Point` is an array of Point records.
+func (g *generator) avroNamedType(t astparser.TypeDef) (interface{}, error) {
+	if g.resolving[t.Name] {
+		return nil, fmt.Errorf("type %s refers to itself, recursive named types are not supported", t.Name)
+	}
+	g.resolving[t.Name] = true
+	defer delete(g.resolving, t.Name)
+
+	return g.avroType(t.Type)
+}
+
+// isNamedType checks if custom type is resolved to its underlying type by avroNamedType.
+func (g *generator) isNamedType(t astparser.TypeCustom) bool {
+	if _, ok := g.typeMap[qualifiedTypeName(t)]; ok {
+		return false
+	}
+	name := g.typeName(t)
+	if _, ok := g.enums[name]; ok {
+		return false
+	}
+	_, ok := g.types[name]
+	return ok
+}
+
+// isDeclared checks if avro name refers struct or enum of the parsed sources which are generated to named avro types.
+// Other named types are resolved to their underlying types and have no avro names.
+func (g *generator) isDeclared(name string) bool {
+	if _, ok := g.structs[name]; ok {
+		return true
+	}
+	_, ok := g.enums[name]
+	return ok
+}
+
+// refersNamedType checks if go type refers named type resolved to its underlying type at any depth.
+func (g *generator) refersNamedType(t astparser.Type) bool {
+	switch v := t.(type) {
+	case astparser.TypePointer:
+		return g.refersNamedType(v.InnerType)
+	case astparser.TypeArray:
+		return g.refersNamedType(v.InnerType)
+	case astparser.TypeMap:
+		return g.refersNamedType(v.KeyType) || g.refersNamedType(v.ValueType)
+	case astparser.TypeCustom:
+		return g.isNamedType(v)
+	default:
+		return false
+	}
+}
+
+// goTypeString formats go type as it is written in the source, e.g. []geo.Point or map[string]Meters.
+func goTypeString(t astparser.Type) string {
+	switch v := t.(type) {
+	case astparser.TypeSimple:
+		return v.Name
+	case astparser.TypePointer:
+		return "*" + goTypeString(v.InnerType)
+	case astparser.TypeArray:
+		if v.Len > 0 {
+			return fmt.Sprintf("[%d]%s", v.Len, goTypeString(v.InnerType))
+		}
+		return "[]" + goTypeString(v.InnerType)
+	case astparser.TypeMap:
+		return fmt.Sprintf("map[%s]%s", goTypeString(v.KeyType), goTypeString(v.ValueType))
+	case astparser.TypeCustom:
+		if v.Package != "" {
+			return path.Base(v.Package) + "." + v.Name
+		}
+		return v.Name
+	case astparser.TypeUnsupported:
+		return v.Name
+	default:
+		return fmt.Sprint(t)
+	}
+}
@@ -0,0 +1,284 @@
+package avro
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Schema returns envelope record of the event protocol as a standalone avro schema, e.g. for .avsc files.
+// Named types are defined inline at their first use and referred by name after that,
+// the record is put to the namespace of the protocol.
+func Schema(protocols map[string]Protocol, name string) (interface{}, error) {
+	p, ok := protocols[name]
+	if !ok {
+		return nil, fmt.Errorf("protocol %s is not found", name)
+	}
+	if len(p.Types) == 0 {
+		return nil, fmt.Errorf("protocol %s has no types", name)
+	}
+
+	// names are qualified to look the types up regardless of the namespace they are referred from
+	types := make([]interface{}, 0, len(p.Types))
+	for _, tpe := range p.Types {
+		types = append(types, qualifiedNames(tpe, p.Namespace))
+	}
+
+	// envelope is the last type of the protocol, it depends on all the others
+	root, ok := types[len(types)-1].(Record)
+	if !ok {
+		return nil, fmt.Errorf("protocol %s does not end with record", name)
+	}
+
+	// types of the protocol could define other types inline, e.g. records of a cycle,
+	// all of them are defined again at their first use from the envelope
+	deps := map[string]dep{}
+	register := func(name string, schema interface{}) {
+		deps[name] = dep{schema: schema}
+	}
+	for _, tpe := range types[:len(types)-1] {
+		namedTypes(tpe, register)
+	}
+	namedTypes(root, register)
+
+	inline := map[string]bool{}
+	for name := range deps {
+		inline[name] = name != root.Name
+	}
+	schema := relativeNames(inlineRecord(deps[root.Name].schema.(Record), deps, inline), p.Namespace).(Record)
+	if !strings.Contains(schema.Name, ".") {
+		schema.Namespace = p.Namespace
+	}
+	return schema, nil
+}
+
+// namedTypes registers named types defined by the schema at any depth, inner types first,
+// and returns the schema which refers them by name.
+func namedTypes(schema interface{}, register func(name string, schema interface{})) interface{} {
+	switch v := schema.(type) {
+	case Record:
+		fields := make([]Field, 0, len(v.Fields))
+		for _, f := range v.Fields {
+			f.Type = namedTypes(f.Type, register)
+			fields = append(fields, f)
+		}
+		v.Fields = fields
+		register(v.Name, v)
+		return v.Name
+	case Enum, Fixed:
+		name := avroSchemaName(v)
+		register(name, v)
+		return name
+	case Array:
+		v.Items = namedTypes(v.Items, register)
+		return v
+	case Map:
+		v.Values = namedTypes(v.Values, register)
+		return v
+	case Union:
+		union := make(Union, 0, len(v))
+		for _, u := range v {
+			union = append(union, namedTypes(u, register))
+		}
+		return union
+	default:
+		return schema
+	}
+}
+
+// qualifiedNames returns the schema with full names of the named types and references to them,
+// short names are resolved against the namespace of the enclosing named type as avro does.
+func qualifiedNames(schema interface{}, namespace string) interface{} {
+	return renameTypes(schema, namespace, func(name, namespace string) (string, string) {
+		name = fullName(name, namespace)
+		return name, namespaceOf(name)
+	})
+}
+
+// relativeNames reverts qualifiedNames for the namespace: full names of its types are shortened
+// where they are resolved against it, names of the other namespaces stay full.
+func relativeNames(schema interface{}, namespace string) interface{} {
+	return renameTypes(schema, namespace, func(name, enclosing string) (string, string) {
+		if enclosing == namespace && namespaceOf(name) == namespace {
+			return shortName(name), namespace
+		}
+		return name, namespaceOf(name)
+	})
+}
+
+// renameTypes returns the schema with the named types and references to them renamed,
+// rename gets the name with the namespace of the enclosing named type
+// and returns the new name with the namespace of the types nested into the renamed one.
+// Explicit namespaces of the named types are joined with their names.
+func renameTypes(schema interface{}, namespace string, rename func(name, namespace string) (string, string)) interface{} {
+	switch v := schema.(type) {
+	case string:
+		if avroIsPrimitiveType(v) {
+			return v
+		}
+		name, _ := rename(v, namespace)
+		return name
+	case Record:
+		name, nested := rename(fullName(v.Name, v.Namespace), namespace)
+		fields := make([]Field, 0, len(v.Fields))
+		for _, f := range v.Fields {
+			f.Type = renameTypes(f.Type, nested, rename)
+			fields = append(fields, f)
+		}
+		v.Name, v.Namespace, v.Fields = name, "", fields
+		return v
+	case Enum:
+		v.Name, _ = rename(fullName(v.Name, v.Namespace), namespace)
+		v.Namespace = ""
+		return v
+	case Fixed:
+		v.Name, _ = rename(fullName(v.Name, v.Namespace), namespace)
+		v.Namespace = ""
+		return v
+	case Array:
+		v.Items = renameTypes(v.Items, namespace, rename)
+		return v
+	case Map:
+		v.Values = renameTypes(v.Values, namespace, rename)
+		return v
+	case Union:
+		union := make(Union, 0, len(v))
+		for _, u := range v {
+			union = append(union, renameTypes(u, namespace, rename))
+		}
+		return union
+	default:
+		return schema
+	}
+}
+
+// parseSchema converts json avro schema to the schema types of the package:
+// named types to Record, Enum and Fixed, complex types to Array, Map and Union,
+// primitive types annotated with logical type to LogicalType. Primitive types with other properties stay maps.
+// Names of the named types are qualified with the namespace they inherit from the enclosing type,
+// as well as the references to them, the top level namespace is the namespace of the protocol.
+func parseSchema(data json.RawMessage, namespace string) (interface{}, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty schema")
+	}
+	switch data[0] {
+	case '"':
+		var name string
+		if err := json.Unmarshal(data, &name); err != nil {
+			return nil, err
+		}
+		if avroIsPrimitiveType(name) {
+			return name, nil
+		}
+		return fullName(name, namespace), nil
+	case '[':
+		var types []json.RawMessage
+		if err := json.Unmarshal(data, &types); err != nil {
+			return nil, err
+		}
+		union := make(Union, 0, len(types))
+		for _, t := range types {
+			u, err := parseSchema(t, namespace)
+			if err != nil {
+				return nil, err
+			}
+			union = append(union, u)
+		}
+		return union, nil
+	}
+
+	var header struct {
+		Type        json.RawMessage `json:"type"`
+		LogicalType string          `json:"logicalType"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, err
+	}
+	var t string
+	if err := json.Unmarshal(header.Type, &t); err != nil {
+		// {"type": {"type": "array", ...}} is the nested schema itself
+		return parseSchema(header.Type, namespace)
+	}
+
+	switch t {
+	case "record", "error":
+		var r struct {
+			Record
+			Fields []struct {
+				Field
+				Type json.RawMessage `json:"type"`
+			} `json:"fields"`
+		}
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, err
+		}
+		r.Name = inheritedName(r.Name, r.Namespace, namespace)
+		fields := make([]Field, 0, len(r.Fields))
+		for _, f := range r.Fields {
+			fieldType, err := parseSchema(f.Type, namespaceOf(fullName(r.Name, r.Namespace)))
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %v", r.Name, f.Name, err)
+			}
+			f.Field.Type = fieldType
+			fields = append(fields, f.Field)
+		}
+		r.Record.Fields = fields
+		return r.Record, nil
+	case "enum":
+		var e Enum
+		err := json.Unmarshal(data, &e)
+		e.Name = inheritedName(e.Name, e.Namespace, namespace)
+		return e, err
+	case "fixed":
+		var f Fixed
+		err := json.Unmarshal(data, &f)
+		f.Name = inheritedName(f.Name, f.Namespace, namespace)
+		return f, err
+	case "array":
+		var a struct {
+			Items json.RawMessage `json:"items"`
+		}
+		if err := json.Unmarshal(data, &a); err != nil {
+			return nil, err
+		}
+		items, err := parseSchema(a.Items, namespace)
+		return Array{Type: "array", Items: items}, err
+	case "map":
+		var m struct {
+			Values json.RawMessage `json:"values"`
+		}
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, err
+		}
+		values, err := parseSchema(m.Values, namespace)
+		return Map{Type: "map", Values: values}, err
+	}
+
+	if !avroIsPrimitiveType(t) {
+		return nil, fmt.Errorf("unexpected type %s", t)
+	}
+	if header.LogicalType != "" {
+		var l LogicalType
+		err := json.Unmarshal(data, &l)
+		return l, err
+	}
+	var properties map[string]interface{}
+	if err := json.Unmarshal(data, &properties); err != nil {
+		return nil, err
+	}
+	if len(properties) == 1 {
+		return t, nil
+	}
+	return properties, nil
+}
+
+// inheritedName qualifies name of the named type with the namespace of the enclosing type
+// unless it is full or has its own namespace.
+func inheritedName(name, own, enclosing string) string {
+	if own != "" {
+		return name
+	}
+	return fullName(name, enclosing)
+}
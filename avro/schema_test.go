@@ -0,0 +1,75 @@
+package avro
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/gojuno/genavro/astparser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchema(t *testing.T) {
+	cfg := astparser.Config{
+		InputDir:      "fixtures_test",
+		IncludeRegexp: "test.go",
+	}
+	sources, err := astparser.Load(cfg)
+	require.NoError(t, err)
+
+	protocols := Generate(sources, "junolab.net")
+	for name := range protocols {
+		schema, err := Schema(protocols, name)
+		require.NoError(t, err)
+		got, err := json.MarshalIndent(schema, "", "    ")
+		require.NoError(t, err)
+
+		var parsed interface{}
+		require.NoError(t, json.Unmarshal(got, &parsed))
+		assertDefinedBeforeUse(t, parsed, map[string]bool{})
+
+		want, err := ioutil.ReadFile(
+			fmt.Sprintf("fixtures_test/%s.avsc", name))
+		require.NoError(t, err)
+		assert.Equal(t, string(want), string(got))
+	}
+
+	_, err = Schema(protocols, "MissingV1")
+	assert.EqualError(t, err, "protocol MissingV1 is not found")
+}
+
+// assertDefinedBeforeUse checks that every named type is defined once and is referred after its definition.
+func assertDefinedBeforeUse(t *testing.T, schema interface{}, defined map[string]bool) {
+	switch v := schema.(type) {
+	case string:
+		if !avroIsPrimitiveType(v) {
+			assert.True(t, defined[v], "%s is referred before definition", v)
+		}
+	case []interface{}:
+		for _, item := range v {
+			assertDefinedBeforeUse(t, item, defined)
+		}
+	case map[string]interface{}:
+		switch v["type"] {
+		case "record", "enum", "fixed":
+			name := v["name"].(string)
+			assert.False(t, defined[name], "%s is defined twice", name)
+			defined[name] = true
+		}
+		if fields, ok := v["fields"].([]interface{}); ok {
+			for _, f := range fields {
+				assertDefinedBeforeUse(t, f.(map[string]interface{})["type"], defined)
+			}
+		}
+		for _, key := range []string{"items", "values"} {
+			if inner, ok := v[key]; ok {
+				assertDefinedBeforeUse(t, inner, defined)
+			}
+		}
+		if inner, ok := v["type"].(map[string]interface{}); ok {
+			assertDefinedBeforeUse(t, inner, defined)
+		}
+	}
+}
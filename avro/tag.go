@@ -0,0 +1,144 @@
+package avro
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const avroTagName = "avro"
+
+// avroOrders are valid values of the field order attribute.
+var avroOrders = map[string]bool{"ascending": true, "descending": true, "ignore": true}
+
+// fieldTag is a parsed `avro:"name=trip_id,type=string,logical=uuid,default=null,order=ignore,alias=old_id"` field tag,
+// decimal fields set precision and scale, e.g. `avro:"logical=decimal,precision=10,scale=2"`.
+// Every key overrides the corresponding aspect of the generated field, json tag is used otherwise.
+// Alias could be repeated to set several aliases.
+type fieldTag struct {
+	Name    string
+	Type    string
+	Logical string
+	Doc     string
+	Default json.RawMessage
+	Order   string
+	Aliases []string
+	// Precision and Scale are attributes of decimal logical type.
+	Precision int
+	Scale     int
+}
+
+// parseFieldTag parses avro tag from the raw field tag.
+func parseFieldTag(tag string) (fieldTag, error) {
+	var t fieldTag
+	value, ok := reflect.StructTag(tag).Lookup(avroTagName)
+	if !ok {
+		return t, nil
+	}
+
+	pairs, err := splitTag(value)
+	if err != nil {
+		return fieldTag{}, err
+	}
+	for _, kv := range pairs {
+		ss := strings.SplitN(kv, "=", 2)
+		if len(ss) != 2 {
+			return fieldTag{}, fmt.Errorf("avro tag %q has no value", kv)
+		}
+
+		key, v := strings.TrimSpace(ss[0]), strings.TrimSpace(ss[1])
+		switch key {
+		case "name":
+			if !avroNameRegexp.MatchString(v) {
+				return fieldTag{}, fmt.Errorf("%q is not a valid avro field name", v)
+			}
+			t.Name = v
+		case "type":
+			t.Type = v
+		case "logical":
+			t.Logical = v
+		case "doc":
+			t.Doc = v
+		case "default":
+			t.Default = defaultLiteral(v)
+		case "order":
+			if !avroOrders[v] {
+				return fieldTag{}, fmt.Errorf("order %q is not one of ascending, descending, ignore", v)
+			}
+			t.Order = v
+		case "precision", "scale":
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return fieldTag{}, fmt.Errorf("%s %q is not a non-negative integer", key, v)
+			}
+			if key == "precision" {
+				t.Precision = n
+			} else {
+				t.Scale = n
+			}
+		case "alias":
+			if !avroNameRegexp.MatchString(v) {
+				return fieldTag{}, fmt.Errorf("%q is not a valid avro alias", v)
+			}
+			t.Aliases = append(t.Aliases, v)
+		default:
+			return fieldTag{}, fmt.Errorf("unknown avro tag key %q", key)
+		}
+	}
+	return t, nil
+}
+
+// splitTag splits avro tag value to key=value pairs by commas,
+// json object, array or string of the default could contain commas, e.g. default={"a":1,"b":2}.
+func splitTag(value string) ([]string, error) {
+	var pairs []string
+	for value != "" {
+		kv, rest := value, ""
+		if i := strings.Index(value, ","); i >= 0 {
+			kv, rest = value[:i], value[i+1:]
+		}
+
+		if ss := strings.SplitN(kv, "=", 2); len(ss) == 2 && strings.TrimSpace(ss[0]) == "default" {
+			start := len(ss[0]) + 1
+			if v := strings.TrimSpace(value[start:]); v != "" && strings.ContainsAny(v[:1], `{["`) {
+				n, err := jsonValueLen(value[start:])
+				if err != nil {
+					return nil, fmt.Errorf("invalid default %s: %v", value[start:], err)
+				}
+				kv, rest = value[:start+n], strings.TrimSpace(value[start+n:])
+				if rest != "" && rest[0] != ',' {
+					return nil, fmt.Errorf("avro tag default %s is followed by %q, expected comma", kv[start:], rest)
+				}
+				rest = strings.TrimPrefix(rest, ",")
+			}
+		}
+
+		if strings.TrimSpace(kv) != "" {
+			pairs = append(pairs, kv)
+		}
+		value = rest
+	}
+	return pairs, nil
+}
+
+// jsonValueLen returns length of the json value the string starts with.
+func jsonValueLen(s string) (int, error) {
+	dec := json.NewDecoder(strings.NewReader(s))
+	var raw json.RawMessage
+	if err := dec.Decode(&raw); err != nil {
+		return 0, err
+	}
+	return int(dec.InputOffset()), nil
+}
+
+// defaultLiteral converts default value from the tag to json,
+// values which are not valid json, e.g. default=active, are strings.
+func defaultLiteral(v string) json.RawMessage {
+	if json.Valid([]byte(v)) {
+		return json.RawMessage(v)
+	}
+	raw, _ := json.Marshal(v)
+	return raw
+}
@@ -0,0 +1,91 @@
+package avro
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+
+	"github.com/gojuno/genavro/astparser"
+	"github.com/pkg/errors"
+)
+
+// defaultTypeMap returns built-in avro schemas of well known custom go types.
+func defaultTypeMap(opts Options) map[string]interface{} {
+	return map[string]interface{}{
+		"junolab.net/lib_api/core.ID": "string",
+		// milliseconds since epoch
+		"junolab.net/lib_api/timeapi.Time": newLogicalType(opts.TimeLogicalType),
+		// milliseconds
+		"junolab.net/lib_api/timeapi.Duration": "long",
+		// RFC3339 string
+		goTimeType: "string",
+		// nanoseconds
+		"time.Duration": "long",
+		// uuid strings
+		"github.com/pborman/uuid.UUID": newLogicalType(logicalUUID),
+		"github.com/google/uuid.UUID":  newLogicalType(logicalUUID),
+		// precision and scale are set with avro tag
+		"github.com/shopspring/decimal.Decimal": newLogicalType(logicalDecimal),
+		"junolab.net/lib_api/decimal.Decimal":   newLogicalType(logicalDecimal),
+	}
+}
+
+// LoadTypeMap reads json file which maps fully qualified go types to avro schemas, e.g.
+//
+//	{
+//		"junolab.net/lib_api/core.ID": {"type": "string", "logicalType": "uuid"},
+//		"github.com/shopspring/decimal.Decimal": "string"
+//	}
+//
+// Types declared in the parsed package are referred by their names only.
+func LoadTypeMap(path string) (map[string]interface{}, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read type map %s", path)
+	}
+
+	typeMap := map[string]interface{}{}
+	if err := json.Unmarshal(bytes, &typeMap); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse type map %s", path)
+	}
+	if err := validTypeMap(typeMap); err != nil {
+		return nil, errors.Wrapf(err, "invalid type map %s", path)
+	}
+
+	return typeMap, nil
+}
+
+// validTypeMap checks that go types are mapped to primitive avro types, optionally with properties,
+// e.g. "string" or {"type": "string", "logicalType": "uuid"}.
+func validTypeMap(typeMap map[string]interface{}) error {
+	names := make([]string, 0, len(typeMap))
+	for name := range typeMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		schema := typeMap[name]
+		var t interface{}
+		switch v := schema.(type) {
+		case string:
+			t = v
+		case map[string]interface{}:
+			t = v["type"]
+		case LogicalType:
+			t = v.Type
+		}
+		if primitive, ok := t.(string); !ok || !avroIsPrimitiveType(primitive) {
+			return errors.Errorf("%s is mapped to %s, expected primitive avro type, e.g. \"string\" or {\"type\": \"string\", \"logicalType\": \"uuid\"}", name, idlJSON(schema))
+		}
+	}
+	return nil
+}
+
+// qualifiedTypeName returns import path with type name, e.g. time.Time or junolab.net/lib_api/core.ID.
+func qualifiedTypeName(t astparser.TypeCustom) string {
+	if t.Package == "" {
+		return t.Name
+	}
+	return t.Package + "." + t.Name
+}
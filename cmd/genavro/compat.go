@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gojuno/genavro/avro"
+)
+
+// checkCompat compares protocols generated to the old and new dirs and returns exit code,
+// it fails if any event present in both dirs does not satisfy required compatibility
+// or any old event is removed, as its readers could not read new data, unless none is required.
+func checkCompat(args []string) int {
+	flags := flag.NewFlagSet("check-compat", flag.ExitOnError)
+	oldDir := flags.String("old", "", "directory with previously generated .avpr protocols")
+	newDir := flags.String("new", "", "directory with newly generated .avpr protocols")
+	require := flags.String("require", "backward", "required compatibility: none, backward, forward or full")
+	flags.Parse(args)
+
+	required := strings.ToUpper(*require)
+	switch required {
+	case avro.CompatibilityNone, avro.CompatibilityBackward, avro.CompatibilityForward, avro.CompatibilityFull:
+	default:
+		log.Fatalf("unknown compatibility %s", *require)
+	}
+
+	oldProtocols, err := loadProtocols(*oldDir)
+	if err != nil {
+		log.Fatalf("failed to load old protocols: %v", err)
+	}
+	newProtocols, err := loadProtocols(*newDir)
+	if err != nil {
+		log.Fatalf("failed to load new protocols: %v", err)
+	}
+
+	names := make([]string, 0, len(newProtocols))
+	for name := range newProtocols {
+		names = append(names, name)
+	}
+	for name := range oldProtocols {
+		if _, ok := newProtocols[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	code := 0
+	for _, name := range names {
+		oldProtocol, hasOld := oldProtocols[name]
+		newProtocol, hasNew := newProtocols[name]
+		switch {
+		case !hasOld:
+			fmt.Printf("%s: added\n", name)
+			continue
+		case !hasNew:
+			fmt.Printf("%s: removed\n", name)
+			if required != avro.CompatibilityNone {
+				code = 1
+			}
+			continue
+		}
+
+		c, err := avro.CompareProtocols(oldProtocol, newProtocol)
+		if err != nil {
+			log.Fatalf("failed to compare %s: %v", name, err)
+		}
+		level := c.Level()
+		fmt.Printf("%s: %s\n", name, level)
+		for _, i := range c.Backward {
+			fmt.Printf("    backward: %s\n", i)
+		}
+		for _, i := range c.Forward {
+			fmt.Printf("    forward: %s\n", i)
+		}
+		if !satisfies(level, required) {
+			code = 1
+		}
+	}
+	return code
+}
+
+func satisfies(level, required string) bool {
+	switch required {
+	case avro.CompatibilityNone:
+		return true
+	case avro.CompatibilityBackward, avro.CompatibilityForward:
+		return level == required || level == avro.CompatibilityFull
+	default:
+		return level == avro.CompatibilityFull
+	}
+}
+
+// loadProtocols reads .avpr files of the dir by protocol file name,
+// dir without protocols is an error as it is rather a wrong path than no events.
+func loadProtocols(dir string) (map[string]avro.Protocol, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("dir is not set")
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.avpr"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no .avpr files in %s", dir)
+	}
+
+	protocols := map[string]avro.Protocol{}
+	for _, path := range paths {
+		bytes, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var p avro.Protocol
+		if err := json.Unmarshal(bytes, &p); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+		}
+		protocols[strings.TrimSuffix(filepath.Base(path), ".avpr")] = p
+	}
+	return protocols, nil
+}
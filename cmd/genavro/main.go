@@ -3,22 +3,43 @@ package main
 import (
 	"encoding/json"
 	"flag"
+	"fmt"
 	"io/ioutil"
 	"log"
+	"os"
 
+	"github.com/gojuno/genavro/astparser"
 	"github.com/gojuno/genavro/avro"
-	"github.com/mkorolyov/astparser"
 )
 
 var (
 	inputDir         = flag.String("in", "", "directory with go files to be parsed")
+	loader           = flag.String("load", "ast", "sources loader: ast parses files of the input dir only, packages type checks the input package and follows types from imported packages")
 	excludeRegexpStr = flag.String("e", "", "exclude regexp to skip files")
 	includeRegexpStr = flag.String("i", "", "include regexp to limit input files")
 	outputDir        = flag.String("o", "", "directory for generated avro schemas")
 	namespace        = flag.String("n", "", "namespace for generated avro schemas")
+	timeLogicalType  = flag.String("time-logical-type", "timestamp-millis", "avro logical type for time fields without avro tag")
+	typeMapPath      = flag.String("type-map", "", "json file which maps fully qualified go types to avro schemas")
+	warnUntagged     = flag.Bool("warn-untagged", false, "warn about exported fields without json tag")
+	goTypeProperty   = flag.Bool("go-type-property", false, "record go type of the fields referring named types in goType field property")
+	strictIntegers   = flag.Bool("strict-integers", true, "widen uint32 to long and map uint64 to -uint64 type, otherwise uint32 is int and uint64 is long")
+	uint64Type       = flag.String("uint64", avro.Uint64Long, "avro type for uint64 fields in strict mode: long, decimal or string")
+	disambiguate     = flag.Bool("disambiguate", false, "name conflicting types from other packages with the namespace of their package instead of failing")
+	eventRegexp      = flag.String("event-regexp", avro.DefaultEventRegexp, "regexp which selects structs generated to separate protocols by name, empty turns selection by name off")
+	eventMarker      = flag.String("event-marker", "", "fully qualified go type which makes the struct embedding it an event, or interface implemented by the events with -load=packages")
+	envelope         = flag.String("envelope", "", "envelope of the events: default, none or name of the struct, struct with //genavro:envelope directive is used if empty")
+	envelopeSchema   = flag.String("envelope-schema", "", "json file with avro record schema of the envelope")
+	payloadField     = flag.String("payload-field", avro.DefaultPayloadField, "name of the envelope field with the payload")
+	format           = flag.String("format", "avpr", "output format: avpr protocols, avsc standalone schemas of the envelopes or avdl avro IDL")
+	fingerprints     = flag.Bool("fingerprints", false, "write fingerprints of the generated types to fingerprints.json in the output dir")
+	verbose          = flag.Bool("v", false, "list selected events")
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "check-compat" {
+		os.Exit(checkCompat(os.Args[2:]))
+	}
 	flag.Parse()
 
 	// load golang sources
@@ -29,21 +50,123 @@ func main() {
 	if *includeRegexpStr != "" {
 		cfg.IncludeRegexp = *includeRegexpStr
 	}
-	sources, err := astparser.Load(cfg)
+	if *eventMarker != "" {
+		cfg.Interfaces = []string{*eventMarker}
+	}
+	var sources map[string]astparser.ParsedFile
+	var err error
+	switch *loader {
+	case "ast":
+		sources, err = astparser.Load(cfg)
+	case "packages":
+		sources, err = astparser.LoadPackages(cfg)
+	default:
+		log.Fatalf("unknown loader %s", *loader)
+	}
 	if err != nil {
 		log.Fatalf("failed to load sources from %s excluding %s: %v", *inputDir, *excludeRegexpStr, err)
 	}
 
+	var typeMap map[string]interface{}
+	if *typeMapPath != "" {
+		typeMap, err = avro.LoadTypeMap(*typeMapPath)
+		if err != nil {
+			log.Fatalf("failed to load type map: %v", err)
+		}
+	}
+
+	switch *format {
+	case "avpr", "avsc", "avdl":
+	default:
+		log.Fatalf("unknown format %s", *format)
+	}
+
 	// generate avro protocols
-	avroProtocols := avro.Generate(sources, *namespace)
+	var envelopeRecord *avro.Record
+	if *envelopeSchema != "" {
+		envelopeRecord, err = avro.LoadEnvelope(*envelopeSchema)
+		if err != nil {
+			log.Fatalf("failed to load envelope: %v", err)
+		}
+	}
+
+	opts := avro.Options{
+		TimeLogicalType: *timeLogicalType,
+		TypeMap:         typeMap,
+		WarnUntagged:    *warnUntagged,
+		GoTypeProperty:  *goTypeProperty,
+		LossyIntegers:   !*strictIntegers,
+		Uint64Type:      *uint64Type,
+		EventRegexp:     *eventRegexp,
+		// explicitly empty regexp selects events by directive and marker only
+		DisableEventRegexp: *eventRegexp == "",
+		EventMarker:        *eventMarker,
+		Envelope:           *envelope,
+		EnvelopeSchema:     envelopeRecord,
+		PayloadField:       *payloadField,
+
+		LoadedPackages:         *loader == "packages",
+		DisambiguateNamespaces: *disambiguate,
+	}
+	avroProtocols, diagnostics, err := avro.GenerateWithOptions(sources, *namespace, opts)
+	if err != nil {
+		log.Fatalf("failed to generate avro protocols: %v", err)
+	}
+
+	if *verbose {
+		events, err := avro.Events(sources, opts)
+		if err != nil {
+			log.Fatalf("failed to select events: %v", err)
+		}
+		for _, e := range events {
+			fmt.Fprintf(os.Stderr, "%s: event %s selected by %s\n", e.Pos, e.Name, e.Reason)
+		}
+	}
+
+	// report all the problems at once
+	for _, d := range diagnostics {
+		fmt.Fprintln(os.Stderr, d)
+	}
+	if avro.HasErrors(diagnostics) {
+		os.Exit(1)
+	}
 
 	// save
 	for f, r := range avroProtocols {
-		filePath := *outputDir + "/" + f + ".avpr"
-		bytes, err := json.MarshalIndent(r, "", "    ")
+		var bytes []byte
+		switch *format {
+		case "avpr":
+			bytes, err = json.MarshalIndent(r, "", "    ")
+		case "avsc":
+			var schema interface{}
+			schema, err = avro.Schema(avroProtocols, f)
+			if err == nil {
+				bytes, err = json.MarshalIndent(schema, "", "    ")
+			}
+		case "avdl":
+			bytes, err = avro.IDL(r)
+		}
 		if err != nil {
 			log.Fatalf("failed to marshall to file %s generated protocol %+v: %v", f, r, err)
 		}
-		ioutil.WriteFile(filePath, bytes, 0666)
+
+		filePath := *outputDir + "/" + f + "." + *format
+		if err := ioutil.WriteFile(filePath, bytes, 0666); err != nil {
+			log.Fatalf("failed to write generated protocol %s: %v", filePath, err)
+		}
+	}
+
+	if *fingerprints {
+		manifest, err := avro.FingerprintsManifest(avroProtocols)
+		if err != nil {
+			log.Fatalf("failed to compute fingerprints: %v", err)
+		}
+		bytes, err := json.MarshalIndent(manifest, "", "    ")
+		if err != nil {
+			log.Fatalf("failed to marshall fingerprints: %v", err)
+		}
+		if err := ioutil.WriteFile(*outputDir+"/fingerprints.json", bytes, 0666); err != nil {
+			log.Fatalf("failed to write fingerprints: %v", err)
+		}
 	}
 }